@@ -4,11 +4,15 @@ import (
 	"context"
 	"exam-backend/controllers"
 	"exam-backend/database"
+	"exam-backend/mailer"
 	"exam-backend/middleware"
 	"exam-backend/models"
+	"exam-backend/signing"
+	"exam-backend/storage"
 	"exam-backend/workers"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -26,11 +30,27 @@ func main() {
 	// AutoMigrate models
 	if err := database.DB.AutoMigrate(
 		&models.User{},
+		&models.StudentGroup{},
 		&models.Exam{},
+		&models.ExamSlot{},
+		&models.SlotBooking{},
 		&models.Question{},
 		&models.ExamAttempt{},
 		&models.QuestionBank{},
 		&models.UserSession{},
+		&models.Announcement{},
+		&models.AnnouncementAck{},
+		&models.AttemptEvent{},
+		&models.AttemptReview{},
+		&models.AttemptSnapshot{},
+		&models.RefreshToken{},
+		&models.UserInvite{},
+		&models.AccountToken{},
+		&models.MFARecoveryCode{},
+		&models.Role{},
+		&models.RolePermission{},
+		&models.UserRole{},
+		&models.UserIdentity{},
 	); err != nil {
 		log.Println("AutoMigrate error:", err)
 	}
@@ -51,6 +71,11 @@ func main() {
 	if err := database.InitializeRedis(redisAddr); err != nil {
 		log.Fatalf("❌ Redis is REQUIRED. Startup failed: %v", err)
 	}
+
+	// built-in roles and their default permissions
+	if err := controllers.SeedRoles(); err != nil {
+		log.Fatalf("❌ Seeding roles failed: %v", err)
+	}
 	log.Println("✅ Redis connected")
 
 	err := database.RedisHealthCheck(context.Background())
@@ -58,13 +83,44 @@ func main() {
 		log.Fatal("Redis unhealthy:", err)
 	}
 	workers.StartAutosaveFlusher()
+	workers.StartEventWriter()
 
 
 	// Start the background worker to clean up old exams
 	controllers.StartExamCleanupTask()
 
+	// Deliver control messages to candidate sockets held by this replica
+	controllers.StartExamControlListener()
+
+	if err := storage.InitSnapshotStore(); err != nil {
+		log.Fatalf("❌ Snapshot store: %v", err)
+	}
+	controllers.StartSnapshotRetentionTask()
+	if err := signing.Init(); err != nil {
+		log.Fatalf("❌ JWT signing keys: %v", err)
+	}
+	if err := controllers.InitSecrets(); err != nil {
+		log.Fatalf("❌ Secrets: %v", err)
+	}
+	if err := mailer.Init(); err != nil {
+		log.Fatalf("❌ Mailer setup failed: %v", err)
+	}
+
 	r := gin.Default()
 
+	// ClientIP() honours X-Forwarded-For only from TRUSTED_PROXIES (comma-separated
+	// IPs/CIDRs). Unset means no proxy is trusted, so candidates cannot spoof their
+	// address past the exam CIDR allowlists or the login IP limits.
+	var trustedProxies []string
+	for _, p := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			trustedProxies = append(trustedProxies, p)
+		}
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatalf("❌ TRUSTED_PROXIES: %v", err)
+	}
+
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
 	config.AllowHeaders = []string{"*"}
@@ -72,51 +128,158 @@ func main() {
 	r.Use(cors.New(config))
 
 	// Auth
+	r.GET("/.well-known/jwks.json", controllers.GetJWKS)
 	r.POST("/api/auth/register", controllers.Register)
-	r.POST("/api/auth/login",middleware.RateLimit("login", 5, time.Minute), controllers.Login)
+	// coarse request cap per IP; failed attempts are limited per IP and per account in controllers/login_guard.go
+	r.POST("/api/auth/login",middleware.RateLimit("login", 60, time.Minute), controllers.Login)
+	r.POST("/api/auth/refresh", middleware.RateLimit("refresh", 20, time.Minute), controllers.RefreshSession)
+	r.GET("/api/auth/invites/:token", middleware.RateLimit("invite", 20, time.Minute), controllers.GetInvite)
+	r.POST("/api/auth/invites/accept", middleware.RateLimit("invite", 20, time.Minute), controllers.AcceptInvite)
+	r.POST("/api/auth/mfa/verify", middleware.RateLimit("mfa_verify", 10, time.Minute), controllers.VerifyMFALogin)
+	r.POST("/api/auth/password/forgot", middleware.RateLimit("forgot_password", 5, time.Minute), controllers.ForgotPassword)
+	r.POST("/api/auth/password/reset", middleware.RateLimit("reset_password", 10, time.Minute), controllers.ResetPassword)
+	r.POST("/api/auth/email/verify", middleware.RateLimit("verify_email", 10, time.Minute), controllers.VerifyEmail)
+	r.POST("/api/auth/email/resend", middleware.RateLimit("resend_verification", 5, time.Minute), controllers.ResendVerificationEmail)
+	r.GET("/api/auth/oidc", controllers.GetSSOConfig)
+	r.GET("/api/auth/oidc/login", middleware.RateLimit("sso_login", 30, time.Minute), controllers.SSOLogin)
+	r.GET("/api/auth/oidc/callback", middleware.RateLimit("sso_callback", 30, time.Minute), controllers.SSOCallback)
+	r.POST("/api/auth/oidc/exchange", middleware.RateLimit("sso_exchange", 30, time.Minute), controllers.SSOExchange)
+	r.GET("/api/time", controllers.GetServerTime)
 	r.GET("/ws/exam", controllers.ExamWebSocket)
+	r.GET("/ws/proctor", controllers.ProctorWebSocket)
 
 	// Protected API
 	api := r.Group("/api")
 	api.Use(middleware.AuthMiddleware())
 	{
+		// sessions
+		api.POST("/auth/logout", controllers.Logout)
+		api.GET("/auth/sessions", controllers.ListMySessions)
+		api.DELETE("/auth/sessions/:id", controllers.RevokeMySession)
+		api.GET("/auth/mfa", controllers.GetMFAStatus)
+		api.POST("/auth/mfa/setup", controllers.BeginMFASetup)
+		api.POST("/auth/mfa/enable", middleware.RateLimit("mfa_enable", 10, time.Minute), controllers.EnableMFA)
+		api.POST("/auth/mfa/disable", middleware.RateLimit("mfa_disable", 5, time.Minute), controllers.DisableMFA)
+		api.POST("/auth/mfa/recovery-codes", middleware.RateLimit("mfa_recovery", 5, time.Minute), controllers.RegenerateRecoveryCodes)
+
 		// exams (shared)
 		api.GET("/exams", controllers.GetExams)
 		api.GET("/exams/:id", controllers.GetExamDetails)
+		api.GET("/exams/:id/slots", controllers.GetExamSlots)
+		api.POST("/exams/:id/slots/:slotId/book", controllers.BookExamSlot)
+		api.DELETE("/exams/:id/booking", controllers.CancelExamBooking)
 		api.POST("/attempts/start",middleware.RateLimit("start_exam", 2, time.Minute), controllers.StartAttempt)
 		api.POST("/progress",middleware.RateLimit("progress", 6, time.Second), controllers.UpdateProgress)
 		api.POST("/attempts/submit",middleware.RateLimit("submit_attempt", 2, time.Minute), controllers.SubmitAttempt)
 		api.GET("/attempts/:id", controllers.GetAttemptDetails)
+		api.GET("/attempts/:id/time", controllers.GetAttemptTime)
+		api.POST("/attempts/:id/snapshots", middleware.RateLimit("snapshot", 12, time.Minute), controllers.UploadSnapshot)
 		api.GET("/student/attempts", controllers.GetStudentAttempts)
 
-		// admin-only
+		// staff routes: every route checks a permission (see models/permissions.go),
+		// so admins reach the teacher routes and proctors only what they are granted
+		perm := middleware.RequirePermission
+
 		admin := api.Group("/admin")
-		admin.Use(middleware.AdminOnly())
+		admin.Use(middleware.RequireMFA())
 		{
-			admin.GET("/exams", controllers.GetExams)
-			admin.POST("/exams", controllers.CreateExam)
-			admin.GET("/exams/:id", controllers.AdminGetExam)
-			admin.DELETE("/exams/:id", controllers.DeleteExam)
+			admin.GET("/permissions", perm(models.PermRoleManage), controllers.ListPermissions)
+			admin.GET("/roles", perm(models.PermRoleManage), controllers.ListRoles)
+			admin.POST("/roles", perm(models.PermRoleManage), controllers.CreateRole)
+			admin.PUT("/roles/:id", perm(models.PermRoleManage), controllers.UpdateRole)
+			admin.DELETE("/roles/:id", perm(models.PermRoleManage), controllers.DeleteRole)
+			admin.GET("/users/:id/roles", perm(models.PermRoleManage), controllers.GetUserRoles)
+			admin.PUT("/users/:id/roles", perm(models.PermRoleManage), controllers.SetUserRoles)
+
+			users := admin.Group("", perm(models.PermUserManage))
+			users.POST("/users", controllers.AdminCreateUser)
+			users.DELETE("/users/:id/mfa", controllers.AdminResetMFA)
+			users.GET("/users/locked", controllers.ListLockedUsers)
+			users.POST("/users/:id/unlock", controllers.AdminUnlockUser)
+			users.DELETE("/login-blocks/:ip", controllers.AdminClearIPBlock)
+			users.POST("/students/import", controllers.ImportStudents)
+			users.GET("/students/import/:id/credentials", controllers.DownloadImportCredentials)
+			users.POST("/invites", controllers.CreateStaffInvite)
+			users.GET("/invites", controllers.ListInvites)
+			users.DELETE("/invites/:id", controllers.RevokeInvite)
+			users.GET("/users/:id/sessions", controllers.AdminListUserSessions)
+			users.DELETE("/users/:id/sessions", controllers.AdminRevokeUserSessions)
+			users.DELETE("/sessions/:id", controllers.AdminRevokeSession)
 
-			admin.PUT("/exams/:id", controllers.UpdateExam)
+			admin.GET("/exams", perm(models.PermExamRead), controllers.GetExams)
+			admin.GET("/exams/:id", perm(models.PermExamRead), controllers.AdminGetExam)
+			admin.POST("/exams", perm(models.PermExamCreate), controllers.CreateExam)
+			admin.POST("/exams/preview", perm(models.PermExamCreate), controllers.ExamBankPreview)
+			admin.GET("/bank/subjects", perm(models.PermExamCreate), controllers.AdminGetSubjects)
+			admin.GET("/bank/topics/:subject", perm(models.PermExamCreate), controllers.AdminGetTopicsForSubject)
+			admin.PUT("/exams/:id", perm(models.PermExamEdit), controllers.UpdateExam)
+			admin.GET("/exams/:id/violation-policy", perm(models.PermExamRead), controllers.GetViolationPolicy)
+			admin.PUT("/exams/:id/violation-policy", perm(models.PermExamEdit), controllers.UpdateViolationPolicy)
+			admin.DELETE("/exams/:id/violation-policy", perm(models.PermExamEdit), controllers.ResetViolationPolicy)
+			admin.DELETE("/exams/:id", perm(models.PermExamDelete), controllers.DeleteExam)
 
-			admin.GET("/exams/:id/attempts", controllers.GetExamAttempts)
-			admin.GET("/attempts/:id", controllers.GetAttemptDetails)
+			// proctors hand the access code out in the hall
+			admin.GET("/exams/:id/access-code", perm(models.PermProctorMonitor), controllers.AdminGetAccessCode)
+			admin.POST("/exams/:id/access-code/rotate", perm(models.PermExamAssign), controllers.AdminRotateAccessCode)
+			admin.DELETE("/exams/:id/access-code", perm(models.PermExamAssign), controllers.AdminDisableAccessCode)
+			admin.PUT("/exams/:id/groups", perm(models.PermExamAssign), controllers.AssignExamGroups)
 
-			admin.POST("/exams/preview", controllers.ExamBankPreview)
+			admin.GET("/exams/:id/slots", perm(models.PermExamRead), controllers.AdminListSlots)
+			admin.GET("/slots/:slotId/bookings", perm(models.PermExamRead), controllers.AdminListSlotBookings)
+			admin.POST("/exams/:id/slots", perm(models.PermExamAssign), controllers.AdminCreateSlot)
+			admin.PUT("/slots/:slotId", perm(models.PermExamAssign), controllers.AdminUpdateSlot)
+			admin.DELETE("/slots/:slotId", perm(models.PermExamAssign), controllers.AdminDeleteSlot)
+			admin.POST("/slots/:slotId/bookings", perm(models.PermExamAssign), controllers.AdminAssignSlot)
+			admin.DELETE("/slots/:slotId/bookings/:studentId", perm(models.PermExamAssign), controllers.AdminRemoveSlotBooking)
 
-			admin.GET("/bank/subjects", controllers.AdminGetSubjects)
-			admin.GET("/bank/topics/:subject", controllers.AdminGetTopicsForSubject)
+			admin.GET("/exams/:id/attempts", perm(models.PermAttemptGrade), controllers.GetExamAttempts)
+			admin.GET("/attempts/:id", perm(models.PermAttemptGrade), controllers.GetAttemptDetails)
+
+			monitor := admin.Group("", perm(models.PermProctorMonitor))
+			monitor.GET("/attempts/:id/events", controllers.GetAttemptEvents)
+			monitor.GET("/exams/:id/violations", controllers.GetExamViolationsReport)
+			monitor.GET("/attempts/:id/snapshots", controllers.ListAttemptSnapshots)
+			monitor.GET("/snapshots/:id/image", controllers.GetSnapshotImage)
+			monitor.GET("/snapshots/:id/thumbnail", controllers.GetSnapshotThumbnail)
+			monitor.GET("/exams/:id/announcements", controllers.ListAnnouncements)
+			monitor.GET("/announcements/:id/acks", controllers.GetAnnouncementAcks)
+
+			intervene := admin.Group("", perm(models.PermProctorIntervene))
+			intervene.POST("/attempts/:id/pause", controllers.PauseAttempt)
+			intervene.POST("/attempts/:id/resume", controllers.ResumeAttempt)
+			intervene.POST("/attempts/:id/terminate", controllers.AdminTerminateAttempt)
+			intervene.POST("/exams/:id/announcements", controllers.CreateAnnouncement)
+
+			review := admin.Group("", perm(models.PermProctorReview))
+			review.POST("/attempts/:id/reinstate", controllers.ReinstateAttempt)
+			review.POST("/attempts/:id/confirm-termination", controllers.ConfirmTermination)
+			review.GET("/attempts/:id/reviews", controllers.ListAttemptReviews)
+
+			admin.GET("/groups", perm(models.PermGroupView), controllers.ListGroups)
+			admin.GET("/groups/:id", perm(models.PermGroupView), controllers.GetGroup)
+			admin.POST("/groups", perm(models.PermGroupManage), controllers.CreateGroup)
+			admin.PUT("/groups/:id", perm(models.PermGroupManage), controllers.UpdateGroup)
+			admin.POST("/groups/:id/members", perm(models.PermGroupManage), controllers.AddGroupMembers)
+			admin.DELETE("/groups/:id/members", perm(models.PermGroupManage), controllers.RemoveGroupMembers)
+			admin.DELETE("/groups/:id", perm(models.PermGroupDelete), controllers.DeleteGroup)
 		}
 
 		teacher := api.Group("/teacher")
-		teacher.Use(middleware.TeacherOnly())
+		teacher.Use(middleware.RequireMFA())
 		{
-			teacher.POST("/question-bank/upload", controllers.TeacherUploadQuestionBank)
-			teacher.GET("/question-bank", controllers.TeacherGetQuestionBank)
-			teacher.PUT("/question-bank/:id", controllers.TeacherUpdateQuestion)
-			teacher.DELETE("/question-bank/:id", controllers.TeacherDeleteQuestion)
-			teacher.GET("/question-bank/template", controllers.TeacherDownloadTemplate)
+			teacher.POST("/question-bank/upload", perm(models.PermBankEdit), controllers.TeacherUploadQuestionBank)
+			teacher.GET("/question-bank", perm(models.PermBankView), controllers.TeacherGetQuestionBank)
+			teacher.PUT("/question-bank/:id", perm(models.PermBankEdit), controllers.TeacherUpdateQuestion)
+			teacher.DELETE("/question-bank/:id", perm(models.PermBankEdit), controllers.TeacherDeleteQuestion)
+			teacher.GET("/question-bank/template", perm(models.PermBankView), controllers.TeacherDownloadTemplate)
+
+			// same handlers as /api/admin/groups, kept for the teacher UI
+			teacher.GET("/groups", perm(models.PermGroupView), controllers.ListGroups)
+			teacher.POST("/groups", perm(models.PermGroupManage), controllers.CreateGroup)
+			teacher.GET("/groups/:id", perm(models.PermGroupView), controllers.GetGroup)
+			teacher.PUT("/groups/:id", perm(models.PermGroupManage), controllers.UpdateGroup)
+			teacher.POST("/groups/:id/members", perm(models.PermGroupManage), controllers.AddGroupMembers)
+			teacher.DELETE("/groups/:id/members", perm(models.PermGroupManage), controllers.RemoveGroupMembers)
 		}
 	}
 
@@ -0,0 +1,293 @@
+package controllers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"exam-backend/database"
+	"exam-backend/mailer"
+	"exam-backend/models"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Password reset and email verification links carry a JWT signed with a key
+// derived from JWT_SECRET, so they can never pass as access tokens (and vice
+// versa). The jti points at an AccountToken row which makes the link single-use.
+//
+// APP_URL: frontend base URL used in links (default http://localhost:5173)
+// PASSWORD_RESET_TTL (default 30m), EMAIL_VERIFY_TTL (default 48h)
+// REQUIRE_EMAIL_VERIFICATION=true: self-registered students cannot log in until verified
+
+var errAccountTokenInvalid = errors.New("invalid_or_expired_token")
+
+func passwordResetTTL() time.Duration { return durationFromEnv("PASSWORD_RESET_TTL", 30*time.Minute) }
+func emailVerifyTTL() time.Duration   { return durationFromEnv("EMAIL_VERIFY_TTL", 48*time.Hour) }
+
+func requireEmailVerification() bool {
+	return strings.EqualFold(os.Getenv("REQUIRE_EMAIL_VERIFICATION"), "true")
+}
+
+func accountTokenKey() []byte {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+	mac.Write([]byte("account-token"))
+	return mac.Sum(nil)
+}
+
+func appBaseURL() string {
+	if base := strings.TrimRight(os.Getenv("APP_URL"), "/"); base != "" {
+		return base
+	}
+	return "http://localhost:5173"
+}
+
+func appLink(path, token string) string {
+	return appBaseURL() + path + "?token=" + url.QueryEscape(token)
+}
+
+// issueAccountToken supersedes any unused token of the same purpose and returns a
+// fresh signed link token.
+func issueAccountToken(tx *gorm.DB, user models.User, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	if err := tx.Model(&models.AccountToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, purpose).
+		Update("used_at", now).Error; err != nil {
+		return "", err
+	}
+
+	row := models.AccountToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		Email:     user.Email,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := tx.Create(&row).Error; err != nil {
+		return "", err
+	}
+
+	claims := jwt.RegisteredClaims{
+		Subject:   user.ID.String(),
+		Audience:  jwt.ClaimStrings{purpose},
+		ExpiresAt: jwt.NewNumericDate(row.ExpiresAt),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ID:        row.ID.String(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(accountTokenKey())
+}
+
+// consumeAccountToken checks the signature, purpose and expiry, marks the row used
+// and returns it with its user. The email on the row must still be the user's.
+func consumeAccountToken(tx *gorm.DB, raw, purpose string) (models.AccountToken, models.User, error) {
+	var row models.AccountToken
+	var user models.User
+
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		return accountTokenKey(), nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithAudience(purpose), jwt.WithExpirationRequired())
+	if err != nil {
+		return row, user, errAccountTokenInvalid
+	}
+	id, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return row, user, errAccountTokenInvalid
+	}
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND purpose = ?", id, purpose).First(&row).Error; err != nil {
+		return row, user, errAccountTokenInvalid
+	}
+	if row.UsedAt != nil || time.Now().After(row.ExpiresAt) || row.UserID.String() != claims.Subject {
+		return row, user, errAccountTokenInvalid
+	}
+	if err := tx.First(&user, "id = ?", row.UserID).Error; err != nil {
+		return row, user, errAccountTokenInvalid
+	}
+	if !strings.EqualFold(user.Email, row.Email) {
+		return row, user, errAccountTokenInvalid
+	}
+
+	if err := tx.Model(&row).Update("used_at", time.Now()).Error; err != nil {
+		return row, user, err
+	}
+	return row, user, nil
+}
+
+// sendMail delivers in the background so response time does not reveal whether
+// an address is registered.
+func sendMail(msg mailer.Message) {
+	if mailer.Default == nil {
+		log.Printf("mailer not configured, dropping mail to %s", msg.To)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := mailer.Default.Send(ctx, msg); err != nil {
+			log.Printf("mail to %s failed: %v", msg.To, err)
+		}
+	}()
+}
+
+func sendVerificationEmail(user models.User) error {
+	token, err := issueAccountToken(database.DB, user, models.TokenPurposeVerifyEmail, emailVerifyTTL())
+	if err != nil {
+		return err
+	}
+	sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Text: fmt.Sprintf("Hello %s,\n\nPlease confirm your email address by opening this link:\n\n%s\n\nThe link expires in %s.\n",
+			user.FullName, appLink("/verify-email", token), emailVerifyTTL()),
+	})
+	return nil
+}
+
+func sendPasswordResetEmail(user models.User) error {
+	token, err := issueAccountToken(database.DB, user, models.TokenPurposePasswordReset, passwordResetTTL())
+	if err != nil {
+		return err
+	}
+	sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Text: fmt.Sprintf("Hello %s,\n\nSomeone asked to reset the password for your account. If it was you, open this link:\n\n%s\n\nThe link expires in %s and can be used once. If you did not ask for this, ignore this email.\n",
+			user.FullName, appLink("/reset-password", token), passwordResetTTL()),
+	})
+	return nil
+}
+
+// POST /api/auth/password/forgot
+// Always answers 200 so the endpoint cannot be used to probe for accounts.
+func ForgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("email = ?", normalizeEmail(input.Email)).First(&user).Error; err == nil {
+		if err := sendPasswordResetEmail(user); err != nil {
+			log.Printf("password reset for %s: %v", user.ID, err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "If the email is registered, a reset link has been sent"})
+}
+
+// POST /api/auth/password/reset
+// Sets the new password and ends every session of the user.
+func ResetPassword(c *gin.Context) {
+	var input struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.Password) < 6 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 6 characters"})
+		return
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		_, user, err := consumeAccountToken(tx, input.Token, models.TokenPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+		// proving control of the mailbox also lifts a brute-force lockout
+		updates := loginResetFields()
+		updates["password"] = string(hashed)
+		// the link went to this address, so it is verified too
+		if user.EmailVerifiedAt == nil {
+			updates["email_verified_at"] = time.Now()
+		}
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+		_, err = revokeSessions(tx, "user_id = ? AND active = true", user.ID)
+		return err
+	})
+	switch {
+	case errors.Is(err, errAccountTokenInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated, please log in again"})
+}
+
+// POST /api/auth/email/verify
+func VerifyEmail(c *gin.Context) {
+	var input struct {
+		Token string `json:"token"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		_, user, err := consumeAccountToken(tx, input.Token, models.TokenPurposeVerifyEmail)
+		if err != nil {
+			return err
+		}
+		if user.EmailVerifiedAt != nil {
+			return nil
+		}
+		return tx.Model(&user).Update("email_verified_at", time.Now()).Error
+	})
+	switch {
+	case errors.Is(err, errAccountTokenInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// POST /api/auth/email/resend
+// Public so that a student blocked by REQUIRE_EMAIL_VERIFICATION can ask again;
+// like ForgotPassword it never reveals whether the address exists.
+func ResendVerificationEmail(c *gin.Context) {
+	var input struct {
+		Email string `json:"email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("email = ?", normalizeEmail(input.Email)).First(&user).Error; err == nil && user.EmailVerifiedAt == nil {
+		if err := sendVerificationEmail(user); err != nil {
+			log.Printf("verification email for %s: %v", user.ID, err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "If the email is registered and not yet verified, a new link has been sent"})
+}
@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"context"
+	"exam-backend/database"
+	"exam-backend/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+func announcementMessage(a models.Announcement) gin.H {
+	return gin.H{
+		"type":       "announcement",
+		"id":         a.ID,
+		"message":    a.Message,
+		"created_at": a.CreatedAt,
+	}
+}
+
+// pendingAnnouncements returns the exam's announcements addressed to the attempt
+// that it has not acknowledged yet (oldest first). Used on (re)connect.
+func pendingAnnouncements(examID, attemptID uuid.UUID) ([]models.Announcement, error) {
+	var all []models.Announcement
+	if err := database.DB.
+		Where("exam_id = ?", examID).
+		Where("id NOT IN (?)", database.DB.Model(&models.AnnouncementAck{}).Select("announcement_id").Where("attempt_id = ?", attemptID)).
+		Order("created_at asc").
+		Find(&all).Error; err != nil {
+		return nil, err
+	}
+
+	out := make([]models.Announcement, 0, len(all))
+	for _, a := range all {
+		if a.TargetsAttempt(attemptID) {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+// ackAnnouncement records that the candidate has seen the announcement (idempotent).
+func ackAnnouncement(ctx context.Context, examID, attemptID uuid.UUID, rawID string) {
+	annID, err := uuid.Parse(rawID)
+	if err != nil {
+		return
+	}
+
+	var ann models.Announcement
+	if err := database.DB.Select("id", "exam_id", "target_attempt_ids").First(&ann, "id = ? AND exam_id = ?", annID, examID).Error; err != nil {
+		return
+	}
+	if !ann.TargetsAttempt(attemptID) {
+		return
+	}
+
+	ack := models.AnnouncementAck{
+		AnnouncementID: annID,
+		AttemptID:      attemptID,
+		AckedAt:        time.Now().UTC(),
+	}
+	result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&ack)
+	if result.Error == nil && result.RowsAffected > 0 {
+		publishProctorEvent(ctx, examID.String(), attemptID.String(), "announcement_ack", map[string]interface{}{
+			"announcement_id": annID,
+		})
+	}
+}
+
+// POST /api/admin/exams/:id/announcements
+func CreateAnnouncement(c *gin.Context) {
+	var req struct {
+		Message    string   `json:"message"`
+		AttemptIDs []string `json:"attempt_ids"` // optional: only these candidates
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Message = strings.TrimSpace(req.Message)
+	if req.Message == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "message is required"})
+		return
+	}
+
+	var exam models.Exam
+	if err := database.DB.Select("id").First(&exam, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+
+	targets := []uuid.UUID{}
+	for _, raw := range req.AttemptIDs {
+		aid, err := uuid.Parse(strings.TrimSpace(raw))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid attempt id: " + raw})
+			return
+		}
+		targets = append(targets, aid)
+	}
+	if len(targets) > 0 {
+		var count int64
+		database.DB.Model(&models.ExamAttempt{}).Where("exam_id = ? AND id IN ?", exam.ID, targets).Count(&count)
+		if count != int64(len(targets)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "one or more attempts do not belong to this exam"})
+			return
+		}
+	}
+
+	adminID, _ := uuid.Parse(c.GetString("userID"))
+	ann := models.Announcement{
+		ExamID:           exam.ID,
+		Message:          req.Message,
+		TargetAttemptIDs: targets,
+		CreatedByID:      adminID,
+	}
+	if err := database.DB.Create(&ann).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save announcement"})
+		return
+	}
+
+	// Push to candidates currently in the exam; the rest get it on (re)connect.
+	var live []models.ExamAttempt
+	query := database.DB.Select("id").Where("exam_id = ? AND submitted_at IS NULL AND is_terminated = false", exam.ID)
+	if len(targets) > 0 {
+		query = query.Where("id IN ?", targets)
+	}
+	query.Find(&live)
+
+	msg := announcementMessage(ann)
+	delivered := 0
+	for _, a := range live {
+		if sendToAttempt(a.ID.String(), msg) {
+			delivered++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"announcement": ann,
+		"recipients":   len(live),
+		"delivered":    delivered,
+	})
+}
+
+// GET /api/admin/exams/:id/announcements
+func ListAnnouncements(c *gin.Context) {
+	examID := c.Param("id")
+
+	var anns []models.Announcement
+	if err := database.DB.Where("exam_id = ?", examID).Order("created_at desc").Find(&anns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load announcements"})
+		return
+	}
+
+	type countRow struct {
+		AnnouncementID uuid.UUID
+		Count          int64
+	}
+	var counts []countRow
+	database.DB.Model(&models.AnnouncementAck{}).
+		Select("announcement_id, COUNT(*) as count").
+		Where("announcement_id IN (?)", database.DB.Model(&models.Announcement{}).Select("id").Where("exam_id = ?", examID)).
+		Group("announcement_id").
+		Scan(&counts)
+	ackMap := map[uuid.UUID]int64{}
+	for _, r := range counts {
+		ackMap[r.AnnouncementID] = r.Count
+	}
+
+	var attemptCount int64
+	database.DB.Model(&models.ExamAttempt{}).Where("exam_id = ?", examID).Count(&attemptCount)
+
+	out := make([]gin.H, 0, len(anns))
+	for _, a := range anns {
+		recipients := attemptCount
+		if len(a.TargetAttemptIDs) > 0 {
+			recipients = int64(len(a.TargetAttemptIDs))
+		}
+		out = append(out, gin.H{
+			"id":                 a.ID,
+			"message":            a.Message,
+			"target_attempt_ids": a.TargetAttemptIDs,
+			"created_by":         a.CreatedByID,
+			"created_at":         a.CreatedAt,
+			"recipients":         recipients,
+			"acknowledged":       ackMap[a.ID],
+		})
+	}
+
+	c.JSON(http.StatusOK, out)
+}
+
+// GET /api/admin/announcements/:id/acks
+// Per-candidate read receipts: who has acknowledged and who hasn't.
+func GetAnnouncementAcks(c *gin.Context) {
+	var ann models.Announcement
+	if err := database.DB.First(&ann, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Announcement not found"})
+		return
+	}
+
+	var attempts []models.ExamAttempt
+	query := database.DB.Preload("Student").Where("exam_id = ?", ann.ExamID)
+	if len(ann.TargetAttemptIDs) > 0 {
+		query = query.Where("id IN ?", ann.TargetAttemptIDs)
+	}
+	if err := query.Order("started_at asc").Find(&attempts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load attempts"})
+		return
+	}
+
+	var acks []models.AnnouncementAck
+	database.DB.Where("announcement_id = ?", ann.ID).Find(&acks)
+	ackedAt := map[uuid.UUID]time.Time{}
+	for _, a := range acks {
+		ackedAt[a.AttemptID] = a.AckedAt
+	}
+
+	out := make([]gin.H, 0, len(attempts))
+	for _, a := range attempts {
+		row := gin.H{
+			"attempt_id":    a.ID,
+			"student_id":    a.StudentID,
+			"student_name":  a.Student.FullName,
+			"student_email": a.Student.Email,
+			"acknowledged":  false,
+			"acked_at":      nil,
+		}
+		if t, ok := ackedAt[a.ID]; ok {
+			row["acknowledged"] = true
+			row["acked_at"] = t
+		}
+		out = append(out, row)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"announcement": ann,
+		"candidates":   out,
+	})
+}
@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"encoding/json"
+	"exam-backend/database"
+	"exam-backend/models"
+	"exam-backend/workers"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Every command received on /ws/exam and every connect/disconnect is stored as an
+// AttemptEvent, so a disputed termination can be reviewed after the exam.
+// Heartbeats are not stored: ws_beat and the connection events already cover them.
+
+// eventSource is what the socket knows about the candidate's connection.
+type eventSource struct {
+	AttemptID   uuid.UUID
+	ExamID      uuid.UUID
+	IP          string
+	Fingerprint string
+}
+
+// Types and payloads come straight from the client, so both are bounded before
+// they reach the event store: Type is a varchar(50) and one bad row would fail
+// the whole batch, and a chatty client should not be able to fill the table.
+const (
+	maxEventTypeLen      = 50
+	maxEventPayloadBytes = 2 << 10
+)
+
+func recordAttemptEvent(src eventSource, eventType string, payload map[string]interface{}) {
+	var clientTime *time.Time
+	if payload != nil {
+		clientTime = parseClientTime(payload["ts"])
+	}
+	if eventType == "" {
+		eventType = "unknown"
+	}
+	workers.EnqueueAttemptEvent(models.AttemptEvent{
+		AttemptID:   src.AttemptID,
+		ExamID:      src.ExamID,
+		Type:        truncateUTF8(eventType, maxEventTypeLen),
+		Payload:     boundedPayload(payload),
+		ClientTime:  clientTime,
+		ServerTime:  time.Now().UTC(),
+		IP:          src.IP,
+		Fingerprint: src.Fingerprint,
+	})
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// boundedPayload keeps payloads up to maxEventPayloadBytes as they are and
+// replaces bigger ones with a marker and a prefix of the encoded JSON.
+func boundedPayload(payload map[string]interface{}) map[string]interface{} {
+	if payload == nil {
+		return nil
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return map[string]interface{}{"truncated": true}
+	}
+	if len(raw) <= maxEventPayloadBytes {
+		return payload
+	}
+	return map[string]interface{}{
+		"truncated": true,
+		"size":      len(raw),
+		"prefix":    truncateUTF8(string(raw), maxEventPayloadBytes),
+	}
+}
+
+// parseClientTime accepts epoch milliseconds (Date.now()) or an RFC3339 string.
+func parseClientTime(v interface{}) *time.Time {
+	var t time.Time
+	switch ts := v.(type) {
+	case float64:
+		if ts <= 0 {
+			return nil
+		}
+		t = time.UnixMilli(int64(ts)).UTC()
+	case string:
+		parsed, err := time.Parse(time.RFC3339, ts)
+		if err != nil {
+			return nil
+		}
+		t = parsed.UTC()
+	default:
+		return nil
+	}
+	return &t
+}
+
+// GET /api/admin/attempts/:id/events?type=tab-switch&since=RFC3339&limit=500
+func GetAttemptEvents(c *gin.Context) {
+	var attempt models.ExamAttempt
+	if err := database.DB.Preload("Student").First(&attempt, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attempt not found"})
+		return
+	}
+
+	limit := 1000
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 5000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 5000"})
+			return
+		}
+		limit = n
+	}
+
+	query := database.DB.Where("attempt_id = ?", attempt.ID)
+	if t := c.Query("type"); t != "" {
+		query = query.Where("type = ?", t)
+	}
+	if raw := c.Query("since"); raw != "" {
+		since, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be RFC3339"})
+			return
+		}
+		query = query.Where("server_time >= ?", since.UTC())
+	}
+
+	var events []models.AttemptEvent
+	if err := query.Order("server_time asc").Limit(limit).Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"attempt_id":    attempt.ID,
+		"exam_id":       attempt.ExamID,
+		"student_name":  attempt.Student.FullName,
+		"student_email": attempt.Student.Email,
+		"events":        events,
+	})
+}
+
+// GET /api/admin/exams/:id/violations
+// One row per attempt with its violation events counted by type.
+func GetExamViolationsReport(c *gin.Context) {
+	var exam models.Exam
+	if err := database.DB.Select("id", "title").First(&exam, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+
+	violationTypes := []string{
+		models.ViolationTabSwitch, models.ViolationFullscreenExit, models.ViolationCopyPaste,
+		models.ViolationDevtools, models.ViolationMultipleFaces, models.ViolationGuardProcessKilled,
+	}
+
+	type countRow struct {
+		AttemptID uuid.UUID
+		Type      string
+		Count     int64
+		LastAt    time.Time
+	}
+	var rows []countRow
+	if err := database.DB.Model(&models.AttemptEvent{}).
+		Select("attempt_id, type, COUNT(*) as count, MAX(server_time) as last_at").
+		Where("exam_id = ? AND type IN ?", exam.ID, violationTypes).
+		Group("attempt_id, type").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build report"})
+		return
+	}
+
+	byAttempt := map[uuid.UUID]map[string]int64{}
+	lastAt := map[uuid.UUID]time.Time{}
+	for _, r := range rows {
+		if byAttempt[r.AttemptID] == nil {
+			byAttempt[r.AttemptID] = map[string]int64{}
+		}
+		byAttempt[r.AttemptID][r.Type] = r.Count
+		if r.LastAt.After(lastAt[r.AttemptID]) {
+			lastAt[r.AttemptID] = r.LastAt
+		}
+	}
+
+	var attempts []models.ExamAttempt
+	if err := database.DB.Preload("Student").Where("exam_id = ?", exam.ID).Order("started_at asc").Find(&attempts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load attempts"})
+		return
+	}
+
+	out := make([]gin.H, 0, len(attempts))
+	for _, a := range attempts {
+		counts := byAttempt[a.ID]
+		if counts == nil && !a.FlaggedForReview && !a.IsTerminated {
+			continue
+		}
+		var total int64
+		for _, n := range counts {
+			total += n
+		}
+		row := gin.H{
+			"attempt_id":         a.ID,
+			"student_id":         a.StudentID,
+			"student_name":       a.Student.FullName,
+			"student_email":      a.Student.Email,
+			"violations":         counts,
+			"total":              total,
+			"last_violation_at":  nil,
+			"flagged_for_review": a.FlaggedForReview,
+			"flag_reasons":       a.FlagReasons,
+			"is_terminated":      a.IsTerminated,
+			"termination_reason": a.TerminationReason,
+			"review_status":      a.ReviewStatus,
+		}
+		if t, ok := lastAt[a.ID]; ok {
+			row["last_violation_at"] = t
+		}
+		out = append(out, row)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"exam_id":  exam.ID,
+		"title":    exam.Title,
+		"attempts": out,
+	})
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"exam-backend/database"
+	"exam-backend/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Redis flag checked by UpdateProgress so the autosave hot path stays DB-free.
+func pausedKey(attemptID string) string {
+	return "attempt:paused:" + attemptID
+}
+
+func isAttemptPaused(ctx context.Context, attemptID string) bool {
+	val, _ := database.RedisGet(ctx, pausedKey(attemptID))
+	return val != ""
+}
+
+// pausedSecondsSoFar includes the pause currently in progress.
+func pausedSecondsSoFar(attempt models.ExamAttempt) int {
+	total := attempt.PausedSeconds
+	if attempt.PausedAt != nil {
+		total += int(time.Since(*attempt.PausedAt).Seconds())
+	}
+	return total
+}
+
+func loadActiveAttemptForProctor(c *gin.Context) (models.ExamAttempt, bool) {
+	var attempt models.ExamAttempt
+	if err := database.DB.Preload("Exam").Preload("Slot").First(&attempt, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attempt not found"})
+		return attempt, false
+	}
+	if attempt.SubmittedAt != nil || attempt.IsTerminated {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "attempt_already_finalized"})
+		return attempt, false
+	}
+	return attempt, true
+}
+
+// POST /api/admin/attempts/:id/pause
+func PauseAttempt(c *gin.Context) {
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	_ = c.ShouldBindJSON(&input)
+
+	attempt, ok := loadActiveAttemptForProctor(c)
+	if !ok {
+		return
+	}
+	if attempt.PausedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "attempt_already_paused"})
+		return
+	}
+
+	now := time.Now().UTC()
+	result := database.DB.Model(&models.ExamAttempt{}).
+		Where("id = ? AND paused_at IS NULL AND submitted_at IS NULL", attempt.ID).
+		Updates(map[string]interface{}{
+			"paused_at":    now,
+			"pause_reason": input.Reason,
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pause attempt"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "attempt_already_paused"})
+		return
+	}
+	attempt.PausedAt = &now
+
+	attemptID := attempt.ID.String()
+	ttl := time.Duration(attempt.Exam.DurationMinutes+180) * time.Minute
+	_ = database.RedisSet(c.Request.Context(), pausedKey(attemptID), "1", ttl)
+
+	timeLeft := computeTimeLeftSeconds(attempt.Exam, attempt)
+	publishProctorEvent(c.Request.Context(), attempt.ExamID.String(), attemptID, "paused", map[string]interface{}{
+		"reason":    input.Reason,
+		"time_left": timeLeft,
+	})
+	sendToAttempt(attemptID, gin.H{
+		"type":      "paused",
+		"reason":    input.Reason,
+		"time_left": timeLeft,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "paused",
+		"paused_at": now,
+		"time_left": timeLeft,
+	})
+}
+
+// POST /api/admin/attempts/:id/resume
+func ResumeAttempt(c *gin.Context) {
+	attempt, ok := loadActiveAttemptForProctor(c)
+	if !ok {
+		return
+	}
+	if attempt.PausedAt == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "attempt_not_paused"})
+		return
+	}
+
+	total := pausedSecondsSoFar(attempt)
+	result := database.DB.Model(&models.ExamAttempt{}).
+		Where("id = ? AND paused_at IS NOT NULL", attempt.ID).
+		Updates(map[string]interface{}{
+			"paused_at":      nil,
+			"pause_reason":   "",
+			"paused_seconds": total,
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resume attempt"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "attempt_not_paused"})
+		return
+	}
+	attempt.PausedAt = nil
+	attempt.PausedSeconds = total
+
+	attemptID := attempt.ID.String()
+	_ = database.RedisDel(c.Request.Context(), pausedKey(attemptID))
+
+	timeLeft := computeTimeLeftSeconds(attempt.Exam, attempt)
+	publishProctorEvent(c.Request.Context(), attempt.ExamID.String(), attemptID, "resumed", map[string]interface{}{
+		"time_left": timeLeft,
+	})
+	delivered := sendToAttempt(attemptID, gin.H{
+		"type":      "resumed",
+		"time_left": timeLeft,
+	})
+
+	// Candidate may have left the room while paused; the disconnect reaper skipped
+	// paused attempts, so restart it if nobody is connected.
+	if val, _ := redisGet("ws_active:" + attemptID); val == "" {
+		go handleDisconnectWithGracePeriod(attemptID, 5*time.Minute)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":         "resumed",
+		"paused_seconds": total,
+		"time_left":      timeLeft,
+		"notified":       delivered,
+	})
+}
+
+// POST /api/admin/attempts/:id/terminate
+// Works from any replica: the candidate's socket is closed wherever it is held.
+func AdminTerminateAttempt(c *gin.Context) {
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	_ = c.ShouldBindJSON(&input)
+	if input.Reason == "" {
+		input.Reason = "terminated_by_proctor"
+	}
+
+	attempt, ok := loadActiveAttemptForProctor(c)
+	if !ok {
+		return
+	}
+
+	attemptID := attempt.ID.String()
+	terminateAttempt(attemptID, input.Reason)
+	_ = database.RedisDel(c.Request.Context(), pausedKey(attemptID))
+
+	closeAttemptSocket(attemptID, input.Reason, gin.H{"type": "terminated", "reason": input.Reason}, "terminated:proctor")
+
+	c.JSON(http.StatusOK, gin.H{"status": "terminated", "reason": input.Reason})
+}
@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"errors"
+	"exam-backend/database"
+	"exam-backend/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Proctor review of terminated attempts. A false positive can be reinstated: the
+// attempt becomes active again and the time between termination and reinstatement
+// is booked as pause time, so the candidate gets back exactly what was left.
+
+var (
+	errNotTerminated       = errors.New("attempt_not_terminated")
+	errActiveAttemptExists = errors.New("active_attempt_exists")
+	errNoTimeLeft          = errors.New("no_time_left")
+)
+
+type reviewInput struct {
+	Reason          string `json:"reason"`
+	ResetViolations bool   `json:"reset_violations"` // reinstate only: clear the counters that caused the termination
+}
+
+func bindReviewInput(c *gin.Context) (reviewInput, bool) {
+	var input reviewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return input, false
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	if input.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return input, false
+	}
+	return input, true
+}
+
+// lockTerminatedAttempt loads the attempt FOR UPDATE so two reviewers can't decide at once.
+func lockTerminatedAttempt(tx *gorm.DB, id string) (models.ExamAttempt, error) {
+	var attempt models.ExamAttempt
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&attempt, "id = ?", id).Error; err != nil {
+		return attempt, err
+	}
+	if !attempt.IsTerminated {
+		return attempt, errNotTerminated
+	}
+	return attempt, nil
+}
+
+func reviewErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "attempt not found"})
+	case errors.Is(err, errNotTerminated), errors.Is(err, errNoTimeLeft):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errActiveAttemptExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		// idx_unique_active_attempt still guards against a concurrent StartAttempt
+		if strings.Contains(err.Error(), "idx_unique_active_attempt") {
+			c.JSON(http.StatusConflict, gin.H{"error": errActiveAttemptExists.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save review"})
+	}
+}
+
+// POST /api/admin/attempts/:id/reinstate
+func ReinstateAttempt(c *gin.Context) {
+	input, ok := bindReviewInput(c)
+	if !ok {
+		return
+	}
+	reviewerID, _ := uuid.Parse(c.GetString("userID"))
+
+	var attempt models.ExamAttempt
+	var review models.AttemptReview
+	var timeLeft int64
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		attempt, err = lockTerminatedAttempt(tx, c.Param("id"))
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&attempt.Exam, "id = ?", attempt.ExamID).Error; err != nil {
+			return err
+		}
+		if attempt.SlotID != nil {
+			var slot models.ExamSlot
+			if err := tx.First(&slot, "id = ?", *attempt.SlotID).Error; err == nil {
+				attempt.Slot = &slot
+			}
+		}
+
+		// partial unique index: only one active attempt per (exam, student)
+		var active int64
+		tx.Model(&models.ExamAttempt{}).
+			Where("exam_id = ? AND student_id = ? AND id <> ? AND submitted_at IS NULL AND is_terminated = false",
+				attempt.ExamID, attempt.StudentID, attempt.ID).
+			Count(&active)
+		if active > 0 {
+			return errActiveAttemptExists
+		}
+
+		// time that was left when the attempt was terminated
+		terminatedAt := time.Now().UTC()
+		if attempt.SubmittedAt != nil {
+			terminatedAt = *attempt.SubmittedAt
+		}
+		if attempt.PausedAt != nil && attempt.PausedAt.Before(terminatedAt) {
+			// terminated while paused: the clock was already frozen
+			terminatedAt = *attempt.PausedAt
+		}
+		frozen := attempt
+		frozen.PausedAt = &terminatedAt
+		if computeTimeLeftSeconds(attempt.Exam, frozen) <= 0 {
+			return errNoTimeLeft
+		}
+
+		attempt.PausedSeconds = pausedSecondsSoFar(frozen)
+		attempt.PausedAt = nil
+		updates := map[string]interface{}{
+			"is_terminated":      false,
+			"termination_reason": "",
+			"submitted_at":       nil,
+			"paused_at":          nil,
+			"pause_reason":       "",
+			"paused_seconds":     attempt.PausedSeconds,
+			"review_status":      models.ReviewDecisionReinstate,
+		}
+		if input.ResetViolations {
+			updates["tab_switches"] = 0
+			updates["violation_counts"] = toJSONString(map[string]int{})
+		}
+		if err := tx.Model(&models.ExamAttempt{}).Where("id = ?", attempt.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		timeLeft = computeTimeLeftSeconds(attempt.Exam, attempt)
+
+		review = models.AttemptReview{
+			AttemptID:         attempt.ID,
+			Decision:          models.ReviewDecisionReinstate,
+			Reason:            input.Reason,
+			TerminationReason: attempt.TerminationReason,
+			TimeLeftSeconds:   timeLeft,
+			ReviewerID:        reviewerID,
+		}
+		return tx.Create(&review).Error
+	})
+	if err != nil {
+		reviewErrorResponse(c, err)
+		return
+	}
+
+	attemptID := attempt.ID.String()
+	ctx := c.Request.Context()
+	rememberAttemptExam(ctx, attemptID, attempt.ExamID.String())
+	_ = database.RedisDel(ctx, pausedKey(attemptID))
+	if input.ResetViolations {
+		// SubmitAttempt and the autosave flusher reload the count from Redis
+		_ = database.RedisDel(ctx, "attempt:tabs:"+attemptID)
+	}
+	publishProctorEvent(ctx, attempt.ExamID.String(), attemptID, "reinstated", map[string]interface{}{
+		"reason":    input.Reason,
+		"time_left": timeLeft,
+	})
+
+	// No disconnect reaper here: the candidate is not connected and first has to
+	// rejoin via StartAttempt (resume path). Their socket arms the reaper when it
+	// closes, as for any other attempt.
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    models.ReviewDecisionReinstate,
+		"review":    review,
+		"time_left": timeLeft,
+	})
+}
+
+// POST /api/admin/attempts/:id/confirm-termination
+func ConfirmTermination(c *gin.Context) {
+	input, ok := bindReviewInput(c)
+	if !ok {
+		return
+	}
+	reviewerID, _ := uuid.Parse(c.GetString("userID"))
+
+	var review models.AttemptReview
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		attempt, err := lockTerminatedAttempt(tx, c.Param("id"))
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ExamAttempt{}).Where("id = ?", attempt.ID).
+			Update("review_status", models.ReviewDecisionConfirm).Error; err != nil {
+			return err
+		}
+		review = models.AttemptReview{
+			AttemptID:         attempt.ID,
+			Decision:          models.ReviewDecisionConfirm,
+			Reason:            input.Reason,
+			TerminationReason: attempt.TerminationReason,
+			ReviewerID:        reviewerID,
+		}
+		return tx.Create(&review).Error
+	})
+	if err != nil {
+		reviewErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": models.ReviewDecisionConfirm,
+		"review": review,
+	})
+}
+
+// GET /api/admin/attempts/:id/reviews
+func ListAttemptReviews(c *gin.Context) {
+	var reviews []models.AttemptReview
+	if err := database.DB.Preload("Reviewer").
+		Where("attempt_id = ?", c.Param("id")).
+		Order("created_at asc").
+		Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load reviews"})
+		return
+	}
+	c.JSON(http.StatusOK, reviews)
+}
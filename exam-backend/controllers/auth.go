@@ -3,13 +3,10 @@ package controllers
 import (
 	"exam-backend/database"
 	"exam-backend/models"
+	"log"
 	"net/http"
-	"os"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -26,6 +23,23 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	if selfRegistrationDisabled() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "registration_disabled"})
+		return
+	}
+
+	// staff accounts are created by admins or through invites
+	if input.Role != "" && input.Role != "student" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "role_not_allowed"})
+		return
+	}
+
+	input.Email = normalizeEmail(input.Email)
+	if !studentEmailAllowed(input.Email) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "email_domain_not_allowed"})
+		return
+	}
+
 	if len(input.Password) < 6 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 6 characters"})
 		return
@@ -38,14 +52,11 @@ func Register(c *gin.Context) {
 	}
 
 	user := models.User{
-		Email:    input.Email,
-		Password: string(hashed),
-		FullName: input.FullName,
-		Role:     input.Role,
-	}
-
-	if user.Role == "" {
-		user.Role = "student"
+		Email:          input.Email,
+		Password:       string(hashed),
+		FullName:       input.FullName,
+		Role:           "student",
+		SelfRegistered: true,
 	}
 
 	if err := database.DB.Create(&user).Error; err != nil {
@@ -53,6 +64,10 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	if err := sendVerificationEmail(user); err != nil {
+		log.Printf("verification email for %s: %v", user.ID, err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "User registered successfully"})
 }
 
@@ -71,16 +86,62 @@ func Login(c *gin.Context) {
 	}
 
 	var user models.User
-	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+	known := database.DB.Where("email = ?", req.Email).First(&user).Error == nil
+	if !known {
+		// same counters and responses as a real account: no enumeration via lockout
+		user = unknownLoginAccount(c.Request.Context(), req.Email)
+	}
+
+	if wait, blocked := ipLoginBlocked(c); blocked {
+		respondLoginBlocked(c, wait, "too_many_failed_logins")
+		return
+	}
+
+	// checked before bcrypt so a locked account cannot be used as a password oracle
+	if wait, code := accountLoginBlocked(user); code != "" {
+		respondLoginBlocked(c, wait, code)
+		return
+	}
+
+	if !known {
+		recordUnknownLoginFailure(c, req.Email)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_credentials"})
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		recordLoginFailure(c, &user)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_credentials"})
 		return
 	}
 
+	// only self-registered accounts: admins, imports, invites and SSO vouch for the rest
+	if requireEmailVerification() && user.SelfRegistered && user.EmailVerifiedAt == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "email_not_verified"})
+		return
+	}
+
+	// second step: the session is only issued by POST /api/auth/mfa/verify
+	if user.MFAEnabled {
+		challenge, err := createMFAChallenge(c, user, req.Fingerprint)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start MFA"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    challenge,
+			"expires_in":   int(mfaChallengeTTL.Seconds()),
+		})
+		return
+	}
+
+	completeLogin(c, user, req.Fingerprint, false)
+}
+
+// completeLogin runs the single-device checks and issues the session once every
+// factor has been verified. Shared by Login and VerifyMFALogin.
+func completeLogin(c *gin.Context, user models.User, fingerprint string, mfa bool) {
 	// ---------------- SECURITY CHECK ----------------
 	// Check if user has an ACTIVE exam
 	var activeAttempt models.ExamAttempt
@@ -107,8 +168,8 @@ func Login(c *gin.Context) {
 			// 2. FINGERPRINT CHECK (The Fix)
 			// If the fingerprints don't match, it means it's a
 			// DIFFERENT screen/window on the SAME computer. BLOCK IT.
-			if activeSession.DeviceFingerprint != "" && req.Fingerprint != "" {
-				if activeSession.DeviceFingerprint != req.Fingerprint {
+			if activeSession.DeviceFingerprint != "" && fingerprint != "" {
+				if activeSession.DeviceFingerprint != fingerprint {
 					c.JSON(http.StatusForbidden, gin.H{
 						"error":   "exam_in_progress",
 						"message": "Login denied. Exam active on another window/browser.",
@@ -120,51 +181,30 @@ func Login(c *gin.Context) {
 	}
 	// ------------------------------------------------
 
-	jti := uuid.New().String()
-
-	claims := &models.Claims{
-		UserID: user.ID.String(),
-		Role:   user.Role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(72 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ID:        jti,
-		},
+	// Invalidate previous sessions
+	if user.Role == "student" {
+		_, _ = revokeSessions(database.DB, "user_id = ? AND active = true", user.ID)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	jwtSecret := []byte(os.Getenv("JWT_SECRET"))
-	tokenString, err := token.SignedString(jwtSecret)
+	tokens, err := startSession(c, user, fingerprint, mfa)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
-
-	// Invalidate previous sessions
-	if user.Role == "student" {
-		database.DB.Model(&models.UserSession{}).Where("user_id = ? AND active = true", user.ID).Update("active", false)
-	}
-
-	exp := time.Now().Add(72 * time.Hour)
-	sess := models.UserSession{
-		ID:                uuid.New(),
-		UserID:            user.ID,
-		Jti:               jti,
-		DeviceFingerprint: req.Fingerprint, // Save fingerprint for the next check
-		IP:                c.ClientIP(),
-		Active:            true,
-		CreatedAt:         time.Now(),
-		ExpiresAt:         &exp,
-	}
-	database.DB.Create(&sess)
-
-	c.JSON(200, gin.H{
-		"token": tokenString,
-		"user": gin.H{
-			"id":        user.ID.String(),
-			"email":     user.Email,
-			"full_name": user.FullName,
-			"role":      user.Role,
-		},
-	})
+	clearLoginFailures(user)
+
+	tokens["user"] = gin.H{
+		"id":             user.ID.String(),
+		"email":          user.Email,
+		"full_name":      user.FullName,
+		"role":           user.Role,
+		"email_verified": user.EmailVerifiedAt != nil,
+		"mfa_enabled":    user.MFAEnabled,
+	}
+	// enforced role without an authenticator: the session works for /api/auth/mfa
+	// enrolment but RequireMFA keeps it out of the admin/teacher routes
+	if !mfa && mfaRequired(c.Request.Context(), user.ID) {
+		tokens["mfa_enrollment_required"] = true
+	}
+	c.JSON(200, tokens)
 }
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"exam-backend/database"
+	"exam-backend/middleware"
 	"exam-backend/models"
 	"net/http"
 	"strconv"
@@ -15,18 +16,21 @@ import (
 func GetExams(c *gin.Context) {
 	var exams []models.Exam
 
-	roleVal, _ := c.Get("role")
-	role, _ := roleVal.(string)
-
 	query := database.DB
-	if role == "student" {
-		query = query.Where("is_active = ?", true)
+	if !middleware.HasPermission(c, models.PermExamRead) {
+		query = query.Where("is_active = ?", true).
+			Where(examVisibleToStudentSQL, c.GetString("userID"))
+	} else {
+		query = query.Preload("Groups")
 	}
 
 	if err := query.Order("created_at desc").Find(&exams).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load exams"})
 		return
 	}
+	for i := range exams {
+		localizeExam(&exams[i])
+	}
 
 	c.JSON(http.StatusOK, exams)
 }
@@ -39,6 +43,10 @@ func AdminGetExam(c *gin.Context) {
 		Preload("Questions", func(db *gorm.DB) *gorm.DB {
 			return db.Order("order_number asc")
 		}).
+		Preload("Groups").
+		Preload("Slots", func(db *gorm.DB) *gorm.DB {
+			return db.Order("start_time asc")
+		}).
 		First(&exam, "id = ?", id).Error
 
 	if err != nil {
@@ -46,6 +54,8 @@ func AdminGetExam(c *gin.Context) {
 		return
 	}
 
+	localizeExam(&exam)
+
 	// count questions
 	easy := 0
 	medium := 0
@@ -70,6 +80,7 @@ func AdminGetExam(c *gin.Context) {
 		"is_active":        exam.IsActive,
 		"start_time":       exam.StartTime,
 		"end_time":         exam.EndTime,
+		"time_zone":        exam.TimeZone,
 		"created_by":       exam.CreatedByID,
 		"created_at":       exam.CreatedAt,
 		"subject":          exam.Subject,
@@ -89,6 +100,14 @@ func AdminGetExam(c *gin.Context) {
 
 		"enable_negative_marking": exam.EnableNegativeMarking,
 
+		"access_code":   exam.AccessCode,
+		"allowed_cidrs": exam.AllowedCIDRs,
+		"groups":        exam.Groups,
+
+		"slots":              exam.Slots,
+		"allow_self_booking": exam.AllowSelfBooking,
+		"violation_policy":   exam.EffectiveViolationPolicy(),
+
 		"easy_count":   easy,
 		"medium_count": medium,
 		"hard_count":   hard,
@@ -98,6 +117,16 @@ func AdminGetExam(c *gin.Context) {
 		"questions": exam.Questions,
 	}
 
+	// exam:read alone (e.g. proctors) must not reveal the answer key or scoring
+	if !middleware.HasPermission(c, models.PermExamEdit) && !middleware.HasPermission(c, models.PermBankView) {
+		questions := sanitizeQuestions(exam.Questions)
+		for _, q := range questions {
+			delete(q, "marks")
+			delete(q, "negative_marks")
+		}
+		response["questions"] = questions
+	}
+
 	c.JSON(200, response)
 }
 
@@ -171,6 +200,9 @@ func GetStudentAttempts(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load attempts"})
 		return
 	}
+	for i := range attempts {
+		localizeExam(&attempts[i].Exam)
+	}
 
 	c.JSON(http.StatusOK, attempts)
 }
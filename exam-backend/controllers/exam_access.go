@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"exam-backend/database"
+	"exam-backend/models"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// No 0/O or 1/I so invigilators can read the code out loud / off a projector.
+const accessCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+const accessCodeLength = 6
+
+func generateAccessCode() (string, error) {
+	max := big.NewInt(int64(len(accessCodeAlphabet)))
+	out := make([]byte, accessCodeLength)
+	for i := range out {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		out[i] = accessCodeAlphabet[n.Int64()]
+	}
+	return string(out), nil
+}
+
+// accessCodeMatches compares case-insensitively in constant time.
+func accessCodeMatches(expected, given string) bool {
+	given = strings.ToUpper(strings.TrimSpace(given))
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(given)) == 1
+}
+
+// normalizeCIDRs validates the allowlist from the admin API.
+// Bare IPs are accepted and stored as /32 (or /128).
+func normalizeCIDRs(in []string) ([]string, error) {
+	out := make([]string, 0, len(in))
+	for _, raw := range in {
+		s := strings.TrimSpace(raw)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP or CIDR '%s'", raw)
+			}
+			if ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP or CIDR '%s'", raw)
+		}
+		out = append(out, ipNet.String())
+	}
+	return out, nil
+}
+
+// clientIPAllowed returns true when the exam has no allowlist or the IP is inside it.
+func clientIPAllowed(exam models.Exam, clientIP string) bool {
+	if len(exam.AllowedCIDRs) == 0 {
+		return true
+	}
+	return ipInCIDRs(clientIP, exam.AllowedCIDRs)
+}
+
+// ipInCIDRs reports whether clientIP falls inside any of the normalized CIDRs.
+func ipInCIDRs(clientIP string, cidrs []string) bool {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// GET /api/admin/exams/:id/access-code
+func AdminGetAccessCode(c *gin.Context) {
+	id := c.Param("id")
+
+	var exam models.Exam
+	if err := database.DB.Select("id", "access_code", "allowed_cidrs").First(&exam, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"exam_id":       exam.ID,
+		"access_code":   exam.AccessCode,
+		"enabled":       exam.AccessCode != "",
+		"allowed_cidrs": exam.AllowedCIDRs,
+	})
+}
+
+// POST /api/admin/exams/:id/access-code/rotate
+// Issues a fresh code (also enables codes on exams that had none).
+// Candidates already inside the exam are not affected; only new starts need the new code.
+func AdminRotateAccessCode(c *gin.Context) {
+	id := c.Param("id")
+
+	var exam models.Exam
+	if err := database.DB.Select("id").First(&exam, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+
+	code, err := generateAccessCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access code"})
+		return
+	}
+
+	if err := database.DB.Model(&models.Exam{}).Where("id = ?", exam.ID).Update("access_code", code).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate access code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"exam_id": exam.ID, "access_code": code})
+}
+
+// DELETE /api/admin/exams/:id/access-code
+func AdminDisableAccessCode(c *gin.Context) {
+	id := c.Param("id")
+
+	result := database.DB.Model(&models.Exam{}).Where("id = ?", id).Update("access_code", "")
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable access code"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Access code disabled"})
+}
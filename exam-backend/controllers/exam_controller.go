@@ -26,6 +26,25 @@ type ExamUpsertRequest struct {
 	StartTime       time.Time `json:"start_time"`
 	IsActive        *bool     `json:"is_active"`
 
+	// Scheduling time zone. StartTimeLocal ("2025-01-10T09:30", no offset) is
+	// interpreted in TimeZone and takes precedence over StartTime.
+	TimeZone       string `json:"time_zone"`
+	StartTimeLocal string `json:"start_time_local"`
+
+	// --- Access Control (optional) ---
+	// nil leaves the current setting untouched on update
+	RequireAccessCode *bool    `json:"require_access_code"`
+	AllowedCIDRs      []string `json:"allowed_cidrs"`
+
+	// Student groups allowed to take the exam (nil = unchanged, [] = everyone)
+	GroupIDs []string `json:"group_ids"`
+
+	// Slots: let students pick their own shift
+	AllowSelfBooking *bool `json:"allow_self_booking"`
+
+	// Proctoring rules (nil = unchanged / default)
+	ViolationPolicy *models.ViolationPolicy `json:"violation_policy"`
+
 	// --- Question Generation Configuration ---
 	TotalQuestions int      `json:"total_questions"`
 	Topics         []string `json:"topics"`         // List of selected topics
@@ -121,10 +140,12 @@ func StartExamCleanupTask() {
 
 func deactivateExpiredExams() {
 	// ... [Existing cleanup logic remains unchanged] ...
-	threshold := time.Now().In(istLocation).Add(-5 * time.Second)
+	threshold := time.Now().Add(-5 * time.Second)
 
+	// multi-slot exams stay active until their last slot has ended
 	result := database.DB.Model(&models.Exam{}).
 		Where("is_active = ? AND end_time IS NOT NULL AND end_time < ?", true, threshold).
+		Where("NOT EXISTS (SELECT 1 FROM exam_slots s WHERE s.exam_id = exams.id AND s.end_time >= ?)", threshold).
 		Update("is_active", false)
 
 	if result.Error != nil {
@@ -302,6 +323,22 @@ func generateQuestionsFromBank(tx *gorm.DB, examID uuid.UUID, req ExamUpsertRequ
 	return nil
 }
 
+// resolveExamStartTime validates the time zone and returns the start time in UTC.
+func resolveExamStartTime(req ExamUpsertRequest, tzName string) (time.Time, error) {
+	loc, err := loadLocation(tzName)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time_zone '%s'", tzName)
+	}
+	if req.StartTimeLocal != "" {
+		t, err := parseLocalTime(req.StartTimeLocal, loc)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return t.UTC(), nil
+	}
+	return req.StartTime.UTC(), nil
+}
+
 // ----------------------
 // UNIFIED HANDLERS
 // ----------------------
@@ -318,6 +355,29 @@ func CreateExam(c *gin.Context) {
 	adminIDStr, _ := uidVal.(string)
 	adminID, _ := uuid.Parse(adminIDStr)
 
+	cidrs, err := normalizeCIDRs(req.AllowedCIDRs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ViolationPolicy != nil {
+		if err := req.ViolationPolicy.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	tzName := req.TimeZone
+	if tzName == "" {
+		tzName = DefaultTimeZoneName()
+	}
+	startTime, err := resolveExamStartTime(req, tzName)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	exam := models.Exam{
 		Title:           req.Title,
 		Description:     req.Description,
@@ -325,7 +385,8 @@ func CreateExam(c *gin.Context) {
 		PassingScore:    req.PassingScore,
 		Subject:         req.Subject,
 		CreatedByID:     adminID,
-		StartTime:       req.StartTime.In(istLocation),
+		StartTime:       startTime,
+		TimeZone:        tzName,
 		IsActive:        true,
 
 		// Map Positive Marks
@@ -338,21 +399,45 @@ func CreateExam(c *gin.Context) {
 		NegativeMarkEasy:      req.NegativeConfig.Easy,
 		NegativeMarkMedium:    req.NegativeConfig.Medium,
 		NegativeMarkHard:      req.NegativeConfig.Hard,
+
+		AllowedCIDRs:    cidrs,
+		ViolationPolicy: req.ViolationPolicy,
 	}
 
 	if req.IsActive != nil {
 		exam.IsActive = *req.IsActive
 	}
+	if req.AllowSelfBooking != nil {
+		exam.AllowSelfBooking = *req.AllowSelfBooking
+	}
+	if req.RequireAccessCode != nil && *req.RequireAccessCode {
+		code, err := generateAccessCode()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access code"})
+			return
+		}
+		exam.AccessCode = code
+	}
 	if exam.DurationMinutes > 0 {
 		exam.EndTime = exam.StartTime.Add(time.Duration(exam.DurationMinutes) * time.Minute)
 	}
 
 	// Transaction: Create Exam -> Generate Questions
-	err := database.DB.Transaction(func(tx *gorm.DB) error {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(&exam).Error; err != nil {
 			return err
 		}
 
+		if req.GroupIDs != nil {
+			groups, err := loadGroupsByID(tx, req.GroupIDs)
+			if err != nil {
+				return errors.New("one or more groups not found")
+			}
+			if err := replaceExamGroups(tx, &exam, groups); err != nil {
+				return err
+			}
+		}
+
 		if req.TotalQuestions > 0 {
 			if err := generateQuestionsFromBank(tx, exam.ID, req); err != nil {
 				return err
@@ -366,7 +451,7 @@ func CreateExam(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Exam created successfully", "id": exam.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "Exam created successfully", "id": exam.ID, "access_code": exam.AccessCode})
 }
 
 // PUT /api/admin/exams/:id
@@ -390,7 +475,18 @@ func UpdateExam(c *gin.Context) {
 	exam.Subject = req.Subject
 	exam.DurationMinutes = req.DurationMinutes
 	exam.PassingScore = req.PassingScore
-	exam.StartTime = req.StartTime.In(istLocation)
+
+	if req.TimeZone != "" {
+		exam.TimeZone = req.TimeZone
+	} else if exam.TimeZone == "" {
+		exam.TimeZone = DefaultTimeZoneName()
+	}
+	startTime, err := resolveExamStartTime(req, exam.TimeZone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	exam.StartTime = startTime
 
 	// Update Positive Marks
 	exam.MarksEasy = req.PointsConfig.Easy
@@ -408,15 +504,58 @@ func UpdateExam(c *gin.Context) {
 	} else {
 		exam.IsActive = true
 	}
+
+	if req.AllowSelfBooking != nil {
+		exam.AllowSelfBooking = *req.AllowSelfBooking
+	}
+	if req.ViolationPolicy != nil {
+		if err := req.ViolationPolicy.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		exam.ViolationPolicy = req.ViolationPolicy
+	}
+
+	// Access control: only touched when the client sends the fields
+	if req.AllowedCIDRs != nil {
+		cidrs, err := normalizeCIDRs(req.AllowedCIDRs)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		exam.AllowedCIDRs = cidrs
+	}
+	if req.RequireAccessCode != nil {
+		if !*req.RequireAccessCode {
+			exam.AccessCode = ""
+		} else if exam.AccessCode == "" {
+			code, err := generateAccessCode()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access code"})
+				return
+			}
+			exam.AccessCode = code
+		}
+	}
 	if exam.DurationMinutes > 0 {
 		exam.EndTime = exam.StartTime.Add(time.Duration(exam.DurationMinutes) * time.Minute)
 	}
 
-	err := database.DB.Transaction(func(tx *gorm.DB) error {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Save(&exam).Error; err != nil {
 			return err
 		}
 
+		if req.GroupIDs != nil {
+			groups, err := loadGroupsByID(tx, req.GroupIDs)
+			if err != nil {
+				return errors.New("one or more groups not found")
+			}
+			if err := replaceExamGroups(tx, &exam, groups); err != nil {
+				return err
+			}
+		}
+
 		// If generation config is provided, DELETE OLD and REGENERATE
 		if req.TotalQuestions > 0 {
 			if err := tx.Where("exam_id = ?", exam.ID).Delete(&models.Question{}).Error; err != nil {
@@ -0,0 +1,522 @@
+package controllers
+
+import (
+	"errors"
+	"exam-backend/database"
+	"exam-backend/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errSlotFull = errors.New("slot_full")
+var errSlotStarted = errors.New("slot_already_started")
+
+type SlotUpsertRequest struct {
+	Label     string    `json:"label"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"` // optional: defaults to start + exam duration
+	Capacity  int       `json:"capacity"`
+
+	// Wall-clock alternatives interpreted in the exam's time zone
+	StartTimeLocal string `json:"start_time_local"`
+	EndTimeLocal   string `json:"end_time_local"`
+}
+
+type SlotSummary struct {
+	models.ExamSlot
+	Booked    int64 `json:"booked"`
+	Available int64 `json:"available"` // -1 = unlimited
+}
+
+// studentSlot returns the slot the student is booked into.
+// hasSlots is false when the exam runs in a single window (no slots defined).
+func studentSlot(examID, studentID uuid.UUID) (*models.ExamSlot, bool, error) {
+	var slotCount int64
+	if err := database.DB.Model(&models.ExamSlot{}).Where("exam_id = ?", examID).Count(&slotCount).Error; err != nil {
+		return nil, false, err
+	}
+	if slotCount == 0 {
+		return nil, false, nil
+	}
+
+	var booking models.SlotBooking
+	err := database.DB.Preload("Slot").
+		Where("exam_id = ? AND student_id = ?", examID, studentID).
+		First(&booking).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, true, nil
+	}
+	if err != nil {
+		return nil, true, err
+	}
+	return &booking.Slot, true, nil
+}
+
+func slotSummaries(examID string) ([]SlotSummary, error) {
+	var exam models.Exam
+	if err := database.DB.Select("id", "time_zone").First(&exam, "id = ?", examID).Error; err != nil {
+		return nil, err
+	}
+	loc := examLocation(exam)
+
+	var slots []models.ExamSlot
+	if err := database.DB.Where("exam_id = ?", examID).Order("start_time asc").Find(&slots).Error; err != nil {
+		return nil, err
+	}
+
+	type countRow struct {
+		SlotID uuid.UUID
+		Count  int64
+	}
+	var counts []countRow
+	if err := database.DB.Model(&models.SlotBooking{}).
+		Select("slot_id, COUNT(*) as count").
+		Where("exam_id = ?", examID).
+		Group("slot_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	countMap := map[uuid.UUID]int64{}
+	for _, r := range counts {
+		countMap[r.SlotID] = r.Count
+	}
+
+	out := make([]SlotSummary, 0, len(slots))
+	for _, s := range slots {
+		s.StartTime = s.StartTime.In(loc)
+		s.EndTime = s.EndTime.In(loc)
+		sum := SlotSummary{ExamSlot: s, Booked: countMap[s.ID], Available: -1}
+		if s.Capacity > 0 {
+			sum.Available = int64(s.Capacity) - sum.Booked
+			if sum.Available < 0 {
+				sum.Available = 0
+			}
+		}
+		out = append(out, sum)
+	}
+	return out, nil
+}
+
+// bookSlot creates or moves a student's booking inside tx.
+// The slot row is locked so concurrent bookings cannot exceed capacity.
+func bookSlot(tx *gorm.DB, slotID, studentID, bookedBy uuid.UUID, ignoreCapacity bool) (*models.SlotBooking, error) {
+	var slot models.ExamSlot
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&slot, "id = ?", slotID).Error; err != nil {
+		return nil, err
+	}
+
+	var existing models.SlotBooking
+	err := tx.Where("exam_id = ? AND student_id = ?", slot.ExamID, studentID).First(&existing).Error
+	if err == nil && existing.SlotID == slot.ID {
+		return &existing, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if !ignoreCapacity && slot.Capacity > 0 {
+		var booked int64
+		if err := tx.Model(&models.SlotBooking{}).Where("slot_id = ?", slot.ID).Count(&booked).Error; err != nil {
+			return nil, err
+		}
+		if booked >= int64(slot.Capacity) {
+			return nil, errSlotFull
+		}
+	}
+
+	if existing.ID != uuid.Nil {
+		existing.SlotID = slot.ID
+		existing.BookedByID = bookedBy
+		if err := tx.Model(&existing).Updates(map[string]interface{}{
+			"slot_id":      slot.ID,
+			"booked_by_id": bookedBy,
+		}).Error; err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	}
+
+	booking := models.SlotBooking{
+		ExamID:     slot.ExamID,
+		StudentID:  studentID,
+		SlotID:     slot.ID,
+		BookedByID: bookedBy,
+	}
+	if err := tx.Create(&booking).Error; err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+// ------------------------- ADMIN -------------------------
+
+// GET /api/admin/exams/:id/slots
+func AdminListSlots(c *gin.Context) {
+	out, err := slotSummaries(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load slots"})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func applySlotRequest(slot *models.ExamSlot, exam models.Exam, req SlotUpsertRequest) error {
+	loc := examLocation(exam)
+	if req.StartTimeLocal != "" {
+		t, err := parseLocalTime(req.StartTimeLocal, loc)
+		if err != nil {
+			return err
+		}
+		req.StartTime = t
+	}
+	if req.EndTimeLocal != "" {
+		t, err := parseLocalTime(req.EndTimeLocal, loc)
+		if err != nil {
+			return err
+		}
+		req.EndTime = t
+	}
+
+	if req.StartTime.IsZero() {
+		return errors.New("start_time is required")
+	}
+	if req.Capacity < 0 {
+		return errors.New("capacity cannot be negative")
+	}
+	end := req.EndTime
+	if end.IsZero() {
+		end = req.StartTime.Add(time.Duration(exam.DurationMinutes) * time.Minute)
+	}
+	if !end.After(req.StartTime) {
+		return errors.New("end_time must be after start_time")
+	}
+
+	slot.Label = strings.TrimSpace(req.Label)
+	slot.StartTime = req.StartTime.UTC()
+	slot.EndTime = end.UTC()
+	slot.Capacity = req.Capacity
+	return nil
+}
+
+// POST /api/admin/exams/:id/slots
+func AdminCreateSlot(c *gin.Context) {
+	var req SlotUpsertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var exam models.Exam
+	if err := database.DB.First(&exam, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+
+	slot := models.ExamSlot{ExamID: exam.ID}
+	if err := applySlotRequest(&slot, exam, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&slot).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create slot"})
+		return
+	}
+
+	c.JSON(http.StatusOK, slot)
+}
+
+// PUT /api/admin/slots/:slotId
+func AdminUpdateSlot(c *gin.Context) {
+	var req SlotUpsertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var slot models.ExamSlot
+	if err := database.DB.First(&slot, "id = ?", c.Param("slotId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Slot not found"})
+		return
+	}
+	var exam models.Exam
+	if err := database.DB.First(&exam, "id = ?", slot.ExamID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+
+	if err := applySlotRequest(&slot, exam, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Save(&slot).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update slot"})
+		return
+	}
+
+	c.JSON(http.StatusOK, slot)
+}
+
+// DELETE /api/admin/slots/:slotId
+// Only slots nobody has started an attempt in can be deleted.
+func AdminDeleteSlot(c *gin.Context) {
+	slotID := c.Param("slotId")
+
+	var used int64
+	database.DB.Model(&models.ExamAttempt{}).Where("slot_id = ?", slotID).Count(&used)
+	if used > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "slot_has_attempts"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("slot_id = ?", slotID).Delete(&models.SlotBooking{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.ExamSlot{}, "id = ?", slotID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Slot not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete slot"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Slot deleted"})
+}
+
+// GET /api/admin/slots/:slotId/bookings
+func AdminListSlotBookings(c *gin.Context) {
+	var bookings []models.SlotBooking
+	if err := database.DB.Preload("Student").
+		Where("slot_id = ?", c.Param("slotId")).
+		Order("created_at asc").
+		Find(&bookings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load bookings"})
+		return
+	}
+	c.JSON(http.StatusOK, bookings)
+}
+
+// POST /api/admin/slots/:slotId/bookings
+// Assigns students to the slot (moving them from another slot of the same exam).
+func AdminAssignSlot(c *gin.Context) {
+	var req struct {
+		GroupMembersRequest
+		IgnoreCapacity bool `json:"ignore_capacity"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	slotID, err := uuid.Parse(c.Param("slotId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid slot id"})
+		return
+	}
+	adminID, _ := uuid.Parse(c.GetString("userID"))
+
+	users, missing, err := resolveStudents(req.GroupMembersRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load students"})
+		return
+	}
+
+	assigned := 0
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, u := range users {
+			if _, err := bookSlot(tx, slotID, u.ID, adminID, req.IgnoreCapacity); err != nil {
+				return err
+			}
+			assigned++
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Slot not found"})
+		return
+	case errors.Is(err, errSlotFull):
+		c.JSON(http.StatusConflict, gin.H{"error": "slot_full", "message": "Slot capacity exceeded. Nothing was assigned."})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign slot"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"assigned": assigned, "not_found": missing})
+}
+
+// DELETE /api/admin/slots/:slotId/bookings/:studentId
+func AdminRemoveSlotBooking(c *gin.Context) {
+	result := database.DB.
+		Where("slot_id = ? AND student_id = ?", c.Param("slotId"), c.Param("studentId")).
+		Delete(&models.SlotBooking{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove booking"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Booking removed"})
+}
+
+// ------------------------- STUDENT -------------------------
+
+// GET /api/exams/:id/slots
+func GetExamSlots(c *gin.Context) {
+	examID := c.Param("id")
+	examUUID, err := uuid.Parse(examID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_exam_id"})
+		return
+	}
+
+	userID := c.GetString("userID")
+	allowed, err := studentCanAccessExam(examUUID, userID)
+	if err != nil || !allowed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+
+	slots, err := slotSummaries(examID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load slots"})
+		return
+	}
+
+	var booking models.SlotBooking
+	var bookedSlot interface{}
+	if err := database.DB.Where("exam_id = ? AND student_id = ?", examID, userID).First(&booking).Error; err == nil {
+		bookedSlot = booking.SlotID
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"slots":       slots,
+		"booked_slot": bookedSlot,
+	})
+}
+
+// POST /api/exams/:id/slots/:slotId/book
+func BookExamSlot(c *gin.Context) {
+	examUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_exam_id"})
+		return
+	}
+	slotID, err := uuid.Parse(c.Param("slotId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_slot_id"})
+		return
+	}
+	userID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user id"})
+		return
+	}
+
+	var exam models.Exam
+	if err := database.DB.First(&exam, "id = ?", examUUID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "exam_not_found"})
+		return
+	}
+	if allowed, err := studentCanAccessExam(exam.ID, userID.String()); err != nil || !allowed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "exam_not_found"})
+		return
+	}
+	if !exam.AllowSelfBooking {
+		c.JSON(http.StatusForbidden, gin.H{"error": "self_booking_disabled"})
+		return
+	}
+
+	var booking *models.SlotBooking
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var slot models.ExamSlot
+		if err := tx.First(&slot, "id = ? AND exam_id = ?", slotID, exam.ID).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		if !now.Before(slot.StartTime) {
+			return errSlotStarted
+		}
+
+		// can't move out of a slot that is already running
+		var current models.SlotBooking
+		if err := tx.Preload("Slot").Where("exam_id = ? AND student_id = ?", exam.ID, userID).First(&current).Error; err == nil {
+			if !now.Before(current.Slot.StartTime) {
+				return errSlotStarted
+			}
+		}
+
+		b, err := bookSlot(tx, slot.ID, userID, userID, false)
+		booking = b
+		return err
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "slot_not_found"})
+		return
+	case errors.Is(err, errSlotFull):
+		c.JSON(http.StatusConflict, gin.H{"error": "slot_full"})
+		return
+	case errors.Is(err, errSlotStarted):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "slot_already_started"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to book slot"})
+		return
+	}
+
+	c.JSON(http.StatusOK, booking)
+}
+
+// DELETE /api/exams/:id/booking
+func CancelExamBooking(c *gin.Context) {
+	examID := c.Param("id")
+	userID := c.GetString("userID")
+
+	var exam models.Exam
+	if err := database.DB.First(&exam, "id = ?", examID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "exam_not_found"})
+		return
+	}
+	if !exam.AllowSelfBooking {
+		c.JSON(http.StatusForbidden, gin.H{"error": "self_booking_disabled"})
+		return
+	}
+
+	var booking models.SlotBooking
+	if err := database.DB.Preload("Slot").Where("exam_id = ? AND student_id = ?", examID, userID).First(&booking).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "booking_not_found"})
+		return
+	}
+	if !time.Now().Before(booking.Slot.StartTime) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "slot_already_started"})
+		return
+	}
+
+	if err := database.DB.Delete(&booking).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel booking"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Booking cancelled"})
+}
@@ -0,0 +1,374 @@
+package controllers
+
+import (
+	"exam-backend/database"
+	"exam-backend/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Exams with no rows in exam_groups are open to every student (pre-groups behaviour).
+// Parenthesised as a whole so the OR cannot escape when combined with other conditions.
+const examVisibleToStudentSQL = `(NOT EXISTS (SELECT 1 FROM exam_groups eg WHERE eg.exam_id = exams.id)
+	OR EXISTS (
+		SELECT 1 FROM exam_groups eg
+		JOIN student_group_members m ON m.student_group_id = eg.student_group_id
+		WHERE eg.exam_id = exams.id AND m.user_id = ?
+	))`
+
+// studentCanAccessExam checks group assignment for a single exam.
+func studentCanAccessExam(examID uuid.UUID, userID string) (bool, error) {
+	var count int64
+	err := database.DB.Model(&models.Exam{}).
+		Where("exams.id = ?", examID).
+		Where(examVisibleToStudentSQL, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+type GroupUpsertRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type GroupMembersRequest struct {
+	UserIDs []string `json:"user_ids"`
+	Emails  []string `json:"emails"`
+}
+
+// GET /api/admin/groups
+func ListGroups(c *gin.Context) {
+	type groupRow struct {
+		models.StudentGroup
+		MemberCount int64 `json:"member_count"`
+	}
+
+	var groups []models.StudentGroup
+	if err := database.DB.Order("name asc").Find(&groups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load groups"})
+		return
+	}
+
+	type countRow struct {
+		StudentGroupID uuid.UUID
+		Count          int64
+	}
+	var counts []countRow
+	database.DB.Table("student_group_members").
+		Select("student_group_id, COUNT(*) as count").
+		Group("student_group_id").
+		Scan(&counts)
+
+	countMap := map[uuid.UUID]int64{}
+	for _, r := range counts {
+		countMap[r.StudentGroupID] = r.Count
+	}
+
+	out := make([]groupRow, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, groupRow{StudentGroup: g, MemberCount: countMap[g.ID]})
+	}
+
+	c.JSON(http.StatusOK, out)
+}
+
+// GET /api/admin/groups/:id
+func GetGroup(c *gin.Context) {
+	id := c.Param("id")
+
+	var group models.StudentGroup
+	if err := database.DB.Preload("Members", func(db *gorm.DB) *gorm.DB {
+		return db.Order("full_name asc")
+	}).First(&group, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+// POST /api/admin/groups
+func CreateGroup(c *gin.Context) {
+	var req GroupUpsertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	creatorID, _ := uuid.Parse(c.GetString("userID"))
+
+	group := models.StudentGroup{
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedByID: creatorID,
+	}
+	if err := database.DB.Create(&group).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not create group. Name might already exist."})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+// PUT /api/admin/groups/:id
+func UpdateGroup(c *gin.Context) {
+	id := c.Param("id")
+
+	var req GroupUpsertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	var group models.StudentGroup
+	if err := database.DB.First(&group, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+
+	group.Name = req.Name
+	group.Description = req.Description
+	if err := database.DB.Save(&group).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not update group. Name might already exist."})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+// DELETE /api/admin/groups/:id
+func DeleteGroup(c *gin.Context) {
+	id := c.Param("id")
+
+	var group models.StudentGroup
+	if err := database.DB.First(&group, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&group).Association("Members").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM exam_groups WHERE student_group_id = ?", group.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&group).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete group"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Group deleted"})
+}
+
+// resolveStudents loads the students referenced by id or email.
+// Returns the list of identifiers that did not match a student account.
+func resolveStudents(req GroupMembersRequest) ([]models.User, []string, error) {
+	var users []models.User
+	var missing []string
+
+	ids := []uuid.UUID{}
+	for _, raw := range req.UserIDs {
+		uid, err := uuid.Parse(strings.TrimSpace(raw))
+		if err != nil {
+			missing = append(missing, raw)
+			continue
+		}
+		ids = append(ids, uid)
+	}
+	emails := []string{}
+	for _, e := range req.Emails {
+		if e = strings.ToLower(strings.TrimSpace(e)); e != "" {
+			emails = append(emails, e)
+		}
+	}
+
+	if len(ids) == 0 && len(emails) == 0 {
+		return users, missing, nil
+	}
+
+	query := database.DB.Where("role = ?", "student")
+	switch {
+	case len(ids) > 0 && len(emails) > 0:
+		query = query.Where("id IN ? OR LOWER(email) IN ?", ids, emails)
+	case len(ids) > 0:
+		query = query.Where("id IN ?", ids)
+	default:
+		query = query.Where("LOWER(email) IN ?", emails)
+	}
+	if err := query.Find(&users).Error; err != nil {
+		return nil, nil, err
+	}
+
+	found := map[string]bool{}
+	for _, u := range users {
+		found[u.ID.String()] = true
+		found[strings.ToLower(u.Email)] = true
+	}
+	for _, uid := range ids {
+		if !found[uid.String()] {
+			missing = append(missing, uid.String())
+		}
+	}
+	for _, e := range emails {
+		if !found[e] {
+			missing = append(missing, e)
+		}
+	}
+
+	return users, missing, nil
+}
+
+// POST /api/admin/groups/:id/members
+func AddGroupMembers(c *gin.Context) {
+	id := c.Param("id")
+
+	var req GroupMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var group models.StudentGroup
+	if err := database.DB.First(&group, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+
+	users, missing, err := resolveStudents(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load students"})
+		return
+	}
+
+	if len(users) > 0 {
+		if err := database.DB.Model(&group).Association("Members").Append(&users); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add members"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"added":     len(users),
+		"not_found": missing,
+	})
+}
+
+// DELETE /api/admin/groups/:id/members
+func RemoveGroupMembers(c *gin.Context) {
+	id := c.Param("id")
+
+	var req GroupMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var group models.StudentGroup
+	if err := database.DB.First(&group, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+
+	users, missing, err := resolveStudents(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load students"})
+		return
+	}
+
+	if len(users) > 0 {
+		if err := database.DB.Model(&group).Association("Members").Delete(&users); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove members"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"removed":   len(users),
+		"not_found": missing,
+	})
+}
+
+// loadGroupsByID validates the ids sent by the admin UI.
+func loadGroupsByID(tx *gorm.DB, raw []string) ([]models.StudentGroup, error) {
+	ids := make([]uuid.UUID, 0, len(raw))
+	seen := map[uuid.UUID]bool{}
+	for _, s := range raw {
+		gid, err := uuid.Parse(strings.TrimSpace(s))
+		if err != nil {
+			return nil, gorm.ErrRecordNotFound
+		}
+		if !seen[gid] { // a repeated id is not a missing group
+			seen[gid] = true
+			ids = append(ids, gid)
+		}
+	}
+
+	groups := []models.StudentGroup{}
+	if len(ids) == 0 {
+		return groups, nil
+	}
+	if err := tx.Where("id IN ?", ids).Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	if len(groups) != len(ids) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return groups, nil
+}
+
+func replaceExamGroups(tx *gorm.DB, exam *models.Exam, groups []models.StudentGroup) error {
+	if len(groups) == 0 {
+		return tx.Model(exam).Association("Groups").Clear()
+	}
+	return tx.Model(exam).Association("Groups").Replace(groups)
+}
+
+// PUT /api/admin/exams/:id/groups
+// Replaces the exam's group assignment. An empty list opens the exam to all students.
+func AssignExamGroups(c *gin.Context) {
+	id := c.Param("id")
+
+	var req struct {
+		GroupIDs []string `json:"group_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var exam models.Exam
+	if err := database.DB.First(&exam, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+
+	groups, err := loadGroupsByID(database.DB, req.GroupIDs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "One or more groups not found"})
+		return
+	}
+
+	if err := replaceExamGroups(database.DB, &exam, groups); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign groups"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exam groups updated", "groups": groups})
+}
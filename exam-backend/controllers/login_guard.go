@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"context"
+	"exam-backend/database"
+	"exam-backend/models"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Brute-force protection for Login and the MFA step, on two independent axes:
+//
+//   - per account (stored on the user): after LOGIN_FREE_FAILURES misses every
+//     further attempt has to wait 1s, 2s, 4s, … (max 60s); after
+//     LOGIN_MAX_FAILURES the account is locked for LOGIN_LOCKOUT. Counts reset
+//     on a successful login, a password reset, an admin unlock, or after an hour
+//     without failures.
+//   - per client IP (Redis): LOGIN_IP_MAX_FAILURES failed attempts within 15
+//     minutes block every login from the IP, known or unknown email, so one
+//     address cannot spray a password across many fresh accounts. Exam halls
+//     where hundreds of candidates share one NAT address go in
+//     LOGIN_IP_ALLOWLIST (IPs/CIDRs) and get LOGIN_IP_ALLOWLIST_MAX_FAILURES.
+//
+// Unknown emails are counted too (in Redis, by hash), so delays and lockouts
+// look the same whether or not an address is registered.
+//
+// LOGIN_FREE_FAILURES (default 3), LOGIN_MAX_FAILURES (default 10),
+// LOGIN_LOCKOUT (default 15m), LOGIN_IP_MAX_FAILURES (default 100),
+// LOGIN_IP_ALLOWLIST_MAX_FAILURES (default 1000)
+
+const (
+	loginFailureReset = time.Hour
+	loginIPWindow     = 15 * time.Minute
+	loginMaxDelay     = 60 * time.Second
+)
+
+func intFromEnv(name string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+func loginIPKey(ip string) string { return "login_fail_ip:" + ip }
+
+func unknownLoginKey(email string) string {
+	return "login_fail_unknown:" + hashToken(strings.ToLower(strings.TrimSpace(email)))
+}
+
+// loginFailureState is the counter kept for an email that has no account.
+type loginFailureState struct {
+	FailedLogins      int        `json:"failed_logins"`
+	LastFailedLoginAt *time.Time `json:"last_failed_login_at"`
+	LockedUntil       *time.Time `json:"locked_until"`
+}
+
+// unknownLoginAccount stands in for the missing user, carrying only the failure
+// counters, so the unknown email goes through the same checks as a real account.
+func unknownLoginAccount(ctx context.Context, email string) models.User {
+	var st loginFailureState
+	_ = database.RedisGetJSON(ctx, unknownLoginKey(email), &st)
+	return models.User{
+		FailedLogins:      st.FailedLogins,
+		LastFailedLoginAt: st.LastFailedLoginAt,
+		LockedUntil:       st.LockedUntil,
+	}
+}
+
+// loginIPAllowlisted reports whether the IP is in LOGIN_IP_ALLOWLIST.
+func loginIPAllowlisted(clientIP string) bool {
+	raw := os.Getenv("LOGIN_IP_ALLOWLIST")
+	if raw == "" {
+		return false
+	}
+	cidrs, err := normalizeCIDRs(strings.Split(raw, ","))
+	if err != nil {
+		log.Printf("LOGIN_IP_ALLOWLIST ignored: %v", err)
+		return false
+	}
+	return ipInCIDRs(clientIP, cidrs)
+}
+
+// loginIPMaxFailures is the failure budget of one client IP per window.
+func loginIPMaxFailures(clientIP string) int {
+	if loginIPAllowlisted(clientIP) {
+		return intFromEnv("LOGIN_IP_ALLOWLIST_MAX_FAILURES", 1000)
+	}
+	return intFromEnv("LOGIN_IP_MAX_FAILURES", 100)
+}
+
+// ipLoginBlocked reports how long the client IP is still blocked.
+func ipLoginBlocked(c *gin.Context) (time.Duration, bool) {
+	ctx := c.Request.Context()
+	raw, err := database.RedisGet(ctx, loginIPKey(c.ClientIP()))
+	if err != nil {
+		return 0, false
+	}
+	if n, _ := strconv.Atoi(raw); n < loginIPMaxFailures(c.ClientIP()) {
+		return 0, false
+	}
+	ttl, err := database.RedisTTL(ctx, loginIPKey(c.ClientIP()))
+	if err != nil || ttl <= 0 {
+		ttl = loginIPWindow
+	}
+	return ttl, true
+}
+
+// failuresStale is true when the stored count no longer applies.
+func failuresStale(user models.User, now time.Time) bool {
+	if user.LockedUntil != nil && !now.Before(*user.LockedUntil) {
+		return true
+	}
+	return user.LastFailedLoginAt == nil || now.Sub(*user.LastFailedLoginAt) > loginFailureReset
+}
+
+// accountLoginBlocked returns the wait and the error code when the account may not
+// try yet: "account_locked" or "login_throttled".
+func accountLoginBlocked(user models.User) (time.Duration, string) {
+	now := time.Now()
+	if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+		return user.LockedUntil.Sub(now), "account_locked"
+	}
+	if failuresStale(user, now) {
+		return 0, ""
+	}
+	over := user.FailedLogins - intFromEnv("LOGIN_FREE_FAILURES", 3)
+	if over <= 0 {
+		return 0, ""
+	}
+	delay := time.Duration(math.Min(math.Pow(2, float64(over-1)), loginMaxDelay.Seconds())) * time.Second
+	if wait := user.LastFailedLoginAt.Add(delay).Sub(now); wait > 0 {
+		return wait, "login_throttled"
+	}
+	return 0, ""
+}
+
+// nextLoginFailure adds one failure to the counters and returns the new count and,
+// once LOGIN_MAX_FAILURES is reached, the end of the lockout.
+func nextLoginFailure(current models.User, now time.Time) (int, *time.Time) {
+	count := current.FailedLogins
+	if failuresStale(current, now) {
+		count = 0
+	}
+	count++
+	if count >= intFromEnv("LOGIN_MAX_FAILURES", 10) {
+		until := now.Add(durationFromEnv("LOGIN_LOCKOUT", 15*time.Minute))
+		return count, &until
+	}
+	return count, nil
+}
+
+// recordUnknownLoginFailure counts a failed attempt for an email with no account.
+func recordUnknownLoginFailure(c *gin.Context, email string) {
+	recordLoginFailure(c, nil)
+
+	ctx := c.Request.Context()
+	now := time.Now()
+	count, until := nextLoginFailure(unknownLoginAccount(ctx, email), now)
+	ttl := loginFailureReset
+	if until != nil && until.Sub(now) > ttl {
+		ttl = until.Sub(now)
+	}
+	st := loginFailureState{FailedLogins: count, LastFailedLoginAt: &now, LockedUntil: until}
+	_ = database.RedisSetJSON(ctx, unknownLoginKey(email), st, ttl)
+}
+
+// recordLoginFailure counts a failed attempt against the IP and, when the
+// account is known, against the account.
+func recordLoginFailure(c *gin.Context, user *models.User) {
+	ctx := c.Request.Context()
+	if n, err := database.RedisIncr(ctx, loginIPKey(c.ClientIP())); err == nil && n == 1 {
+		_ = database.RedisExpire(ctx, loginIPKey(c.ClientIP()), loginIPWindow)
+	}
+	if user == nil {
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "failed_logins", "last_failed_login_at", "locked_until").
+			First(&current, "id = ?", user.ID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		count, until := nextLoginFailure(current, now)
+		updates := map[string]interface{}{
+			"failed_logins":        count,
+			"last_failed_login_at": now,
+			"locked_until":         until,
+		}
+		if until != nil {
+			log.Printf("🔒 account %s locked until %s after %d failed logins (last from %s)",
+				user.ID, until.Format(time.RFC3339), count, c.ClientIP())
+		}
+		return tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(updates).Error
+	})
+	if err != nil {
+		log.Printf("login failure for %s not recorded: %v", user.ID, err)
+	}
+}
+
+func loginResetFields() map[string]interface{} {
+	return map[string]interface{}{
+		"failed_logins":        0,
+		"last_failed_login_at": nil,
+		"locked_until":         nil,
+	}
+}
+
+// clearLoginFailures is called once a login fully succeeds.
+func clearLoginFailures(user models.User) {
+	if user.FailedLogins == 0 && user.LockedUntil == nil {
+		return
+	}
+	database.DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(loginResetFields())
+}
+
+func respondLoginBlocked(c *gin.Context, wait time.Duration, code string) {
+	secs := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(secs))
+	status := http.StatusTooManyRequests
+	if code == "account_locked" {
+		status = http.StatusLocked
+	}
+	c.JSON(status, gin.H{"error": code, "retry_after": secs})
+}
+
+// lockedUserRow is the only place the lockout counters leave the server;
+// models.User keeps them out of every other response.
+type lockedUserRow struct {
+	ID                uuid.UUID  `json:"id"`
+	Email             string     `json:"email"`
+	FullName          string     `json:"full_name"`
+	Role              string     `json:"role"`
+	RollNumber        string     `json:"roll_number,omitempty"`
+	FailedLogins      int        `json:"failed_logins"`
+	LastFailedLoginAt *time.Time `json:"last_failed_login_at"`
+	LockedUntil       *time.Time `json:"locked_until"`
+}
+
+// GET /api/admin/users/locked
+func ListLockedUsers(c *gin.Context) {
+	var users []models.User
+	database.DB.Where("locked_until > ?", time.Now()).Order("locked_until desc").Find(&users)
+
+	out := make([]lockedUserRow, 0, len(users))
+	for _, u := range users {
+		out = append(out, lockedUserRow{
+			ID:                u.ID,
+			Email:             u.Email,
+			FullName:          u.FullName,
+			Role:              u.Role,
+			RollNumber:        u.RollNumber,
+			FailedLogins:      u.FailedLogins,
+			LastFailedLoginAt: u.LastFailedLoginAt,
+			LockedUntil:       u.LockedUntil,
+		})
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// POST /api/admin/users/:id/unlock
+func AdminUnlockUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	res := database.DB.Model(&models.User{}).Where("id = ?", userID).Updates(loginResetFields())
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+}
+
+// DELETE /api/admin/login-blocks/:ip
+// Lifts an IP block, e.g. for an exam hall that tripped the failure limit.
+func AdminClearIPBlock(c *gin.Context) {
+	if err := database.RedisDel(c.Request.Context(), loginIPKey(c.Param("ip"))); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear block"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "IP unblocked"})
+}
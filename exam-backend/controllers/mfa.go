@@ -0,0 +1,524 @@
+package controllers
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"exam-backend/database"
+	"exam-backend/middleware"
+	"exam-backend/models"
+	"exam-backend/totp"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TOTP second factor. Login answers {"mfa_required": true, "mfa_token"} for
+// enrolled users and the session is only issued by POST /api/auth/mfa/verify.
+// Sessions that passed a second factor carry "mfa": true in their access token,
+// which middleware.RequireMFA checks for roles in MFA_REQUIRED_ROLES.
+// Enforcement looks at every role a user holds, not just users.role.
+//
+// MFA_ISSUER: name shown in authenticator apps (default "Online Exam")
+// MFA_ENCRYPTION_KEY: key for sealing TOTP secrets at rest (default: JWT_SECRET)
+
+const (
+	mfaChallengeTTL      = 5 * time.Minute
+	mfaChallengeAttempts = 5
+	mfaSetupTTL          = 10 * time.Minute
+	mfaRecoveryCodeCount = 10
+	mfaClockSkew         = 1 // accept the previous and next 30s step
+)
+
+var (
+	errMFAInvalidCode = errors.New("invalid_mfa_code")
+	errMFANotEnabled  = errors.New("mfa_not_enabled")
+)
+
+type mfaChallenge struct {
+	UserID      string `json:"user_id"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+func mfaChallengeKey(token string) string { return "mfa_challenge:" + hashToken(token) }
+func mfaAttemptsKey(token string) string  { return "mfa_challenge_attempts:" + hashToken(token) }
+func mfaSetupKey(userID uuid.UUID) string { return "mfa_setup:" + userID.String() }
+
+// mfaRequired fails closed: a user whose roles cannot be loaded is treated as
+// required, so a database hiccup never waives the second factor.
+func mfaRequired(ctx context.Context, userID uuid.UUID) bool {
+	required, err := middleware.MFARequiredForUser(ctx, userID.String())
+	return err != nil || required
+}
+
+func mfaIssuer() string {
+	if v := os.Getenv("MFA_ISSUER"); v != "" {
+		return v
+	}
+	return "Online Exam"
+}
+
+func mfaCipher() (cipher.AEAD, error) {
+	secret := os.Getenv("MFA_ENCRYPTION_KEY")
+	if secret == "" {
+		secret = os.Getenv("JWT_SECRET")
+	}
+	if secret == "" {
+		return nil, errors.New("no MFA encryption key configured")
+	}
+	key := sha256.Sum256([]byte("mfa-secret:" + secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealMFASecret(plain string) (string, error) {
+	gcm, err := mfaCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plain), nil)), nil
+}
+
+func openMFASecret(sealed string) (string, error) {
+	gcm, err := mfaCipher()
+	if err != nil {
+		return "", err
+	}
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < gcm.NonceSize() {
+		return "", errors.New("corrupt mfa secret")
+	}
+	plain, err := gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// createMFAChallenge parks a password-verified login until the second factor arrives.
+func createMFAChallenge(c *gin.Context, user models.User, fingerprint string) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	ch := mfaChallenge{UserID: user.ID.String(), Fingerprint: fingerprint}
+	if err := database.RedisSetJSON(c.Request.Context(), mfaChallengeKey(token), ch, mfaChallengeTTL); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// checkTOTP validates code for user and records the step so it cannot be replayed.
+func checkTOTP(tx *gorm.DB, user *models.User, code string) error {
+	if !user.MFAEnabled || user.MFASecret == "" {
+		return errMFANotEnabled
+	}
+	secret, err := openMFASecret(user.MFASecret)
+	if err != nil {
+		return err
+	}
+	counter, ok := totp.Validate(secret, code, time.Now(), mfaClockSkew)
+	if !ok || counter <= user.MFALastCounter {
+		return errMFAInvalidCode
+	}
+	res := tx.Model(&models.User{}).
+		Where("id = ? AND mfa_last_counter < ?", user.ID, counter).
+		Update("mfa_last_counter", counter)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errMFAInvalidCode // same step used concurrently
+	}
+	user.MFALastCounter = counter
+	return nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// useRecoveryCode burns one unused recovery code of the user.
+func useRecoveryCode(tx *gorm.DB, userID uuid.UUID, code string) error {
+	code = normalizeRecoveryCode(code)
+	if code == "" {
+		return errMFAInvalidCode
+	}
+	var rc models.MFARecoveryCode
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashToken(code)).
+		First(&rc).Error; err != nil {
+		return errMFAInvalidCode
+	}
+	return tx.Model(&rc).Update("used_at", time.Now()).Error
+}
+
+// checkSecondFactor accepts either a TOTP code or a recovery code.
+func checkSecondFactor(tx *gorm.DB, user *models.User, code, recoveryCode string) error {
+	if !user.MFAEnabled {
+		return errMFANotEnabled
+	}
+	if recoveryCode != "" {
+		return useRecoveryCode(tx, user.ID, recoveryCode)
+	}
+	return checkTOTP(tx, user, code)
+}
+
+// replaceRecoveryCodes discards the user's old codes and returns fresh ones,
+// formatted XXXXX-XXXXX. They are shown once.
+func replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	const alphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	codes := make([]string, 0, mfaRecoveryCodeCount)
+	for i := 0; i < mfaRecoveryCodeCount; i++ {
+		b := make([]byte, 10)
+		for j := range b {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+			if err != nil {
+				return nil, err
+			}
+			b[j] = alphabet[n.Int64()]
+		}
+		raw := string(b)
+		if err := tx.Create(&models.MFARecoveryCode{UserID: userID, CodeHash: hashToken(raw)}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+	}
+	return codes, nil
+}
+
+func clearMFA(tx *gorm.DB, userID uuid.UUID) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"mfa_enabled":      false,
+		"mfa_secret":       "",
+		"mfa_last_counter": 0,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error
+}
+
+func mfaErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errMFAInvalidCode):
+		return http.StatusUnauthorized
+	case errors.Is(err, errMFANotEnabled):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// POST /api/auth/mfa/verify
+// Second login step: {mfa_token, code} or {mfa_token, recovery_code}.
+func VerifyMFALogin(c *gin.Context) {
+	var input struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.MFAToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_token is required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var ch mfaChallenge
+	if err := database.RedisGetJSON(ctx, mfaChallengeKey(input.MFAToken), &ch); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_or_expired_mfa_token"})
+		return
+	}
+
+	attempts, _ := database.RedisIncr(ctx, mfaAttemptsKey(input.MFAToken))
+	if attempts == 1 {
+		_ = database.RedisExpire(ctx, mfaAttemptsKey(input.MFAToken), mfaChallengeTTL)
+	}
+	if attempts > mfaChallengeAttempts {
+		_ = database.RedisDel(ctx, mfaChallengeKey(input.MFAToken))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too_many_attempts", "message": "Log in again"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", ch.UserID).Error; err != nil || !user.MFAEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_or_expired_mfa_token"})
+		return
+	}
+	if wait, code := accountLoginBlocked(user); code != "" {
+		respondLoginBlocked(c, wait, code)
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return checkSecondFactor(tx, &user, input.Code, input.RecoveryCode)
+	})
+	if err != nil {
+		// wrong codes count like wrong passwords, otherwise a stolen password
+		// would buy unlimited guesses through fresh challenges
+		if errors.Is(err, errMFAInvalidCode) {
+			recordLoginFailure(c, &user)
+		}
+		c.JSON(mfaErrorStatus(err), gin.H{"error": errMFAInvalidCode.Error()})
+		return
+	}
+
+	_ = database.RedisDel(ctx, mfaChallengeKey(input.MFAToken))
+	_ = database.RedisDel(ctx, mfaAttemptsKey(input.MFAToken))
+	completeLogin(c, user, ch.Fingerprint, true)
+}
+
+// GET /api/auth/mfa
+func GetMFAStatus(c *gin.Context) {
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", c.GetString("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	var remaining int64
+	database.DB.Model(&models.MFARecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&remaining)
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  user.MFAEnabled,
+		"required":                 mfaRequired(c.Request.Context(), user.ID),
+		"session_verified":         c.GetBool("mfa"),
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// POST /api/auth/mfa/setup
+// Starts enrolment; nothing changes until POST /api/auth/mfa/enable confirms a code.
+func BeginMFASetup(c *gin.Context) {
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", c.GetString("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.MFAEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "mfa_already_enabled"})
+		return
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	sealed, err := sealMFASecret(secret)
+	if err == nil {
+		err = database.RedisSet(c.Request.Context(), mfaSetupKey(user.ID), sealed, mfaSetupTTL)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start MFA setup"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_url": totp.URI(mfaIssuer(), user.Email, secret),
+		"expires_in":  int(mfaSetupTTL.Seconds()),
+	})
+}
+
+// POST /api/auth/mfa/enable
+// Confirms enrolment with a first code. Returns the recovery codes (shown once)
+// and a new access token for the now MFA-verified session; other sessions end.
+func EnableMFA(c *gin.Context) {
+	var input struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", c.GetString("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.MFAEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "mfa_already_enabled"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	sealed, err := database.RedisGet(ctx, mfaSetupKey(user.ID))
+	if err != nil || sealed == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_setup_expired"})
+		return
+	}
+	secret, err := openMFASecret(sealed)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_setup_expired"})
+		return
+	}
+	counter, ok := totp.Validate(secret, input.Code, time.Now(), mfaClockSkew)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errMFAInvalidCode.Error()})
+		return
+	}
+
+	jti := c.GetString("jti")
+	var codes []string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"mfa_enabled":      true,
+			"mfa_secret":       sealed,
+			"mfa_last_counter": counter,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		if codes, err = replaceRecoveryCodes(tx, user.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.UserSession{}).Where("jti = ?", jti).Update("mfa_verified", true).Error; err != nil {
+			return err
+		}
+		_, err = revokeSessions(tx, "user_id = ? AND jti <> ? AND active = true", user.ID, jti)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable MFA"})
+		return
+	}
+	_ = database.RedisDel(ctx, mfaSetupKey(user.ID))
+
+	access, err := signAccessToken(user, jti, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "MFA enabled",
+		"recovery_codes": codes,
+		"token":          access,
+		"token_type":     "Bearer",
+		"expires_in":     int(accessTokenTTL().Seconds()),
+	})
+}
+
+// POST /api/auth/mfa/disable
+// Needs the password and a current code; not allowed for roles that require MFA.
+func DisableMFA(c *gin.Context) {
+	var input struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", c.GetString("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if mfaRequired(c.Request.Context(), user.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "mfa_required_for_role"})
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_credentials"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkSecondFactor(tx, &user, input.Code, input.RecoveryCode); err != nil {
+			return err
+		}
+		return clearMFA(tx, user.ID)
+	})
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "MFA disabled"})
+}
+
+// POST /api/auth/mfa/recovery-codes
+// Replaces all recovery codes; needs a current TOTP code.
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var input struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", c.GetString("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkTOTP(tx, &user, input.Code); err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DELETE /api/admin/users/:id/mfa
+// For a lost authenticator: removes the user's TOTP and ends their sessions so
+// the next login goes through enrolment again.
+func AdminResetMFA(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := clearMFA(tx, user.ID); err != nil {
+			return err
+		}
+		_, err := revokeSessions(tx, "user_id = ? AND active = true", user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset MFA"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "MFA reset"})
+}
@@ -0,0 +1,523 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"exam-backend/database"
+	"exam-backend/middleware"
+	"exam-backend/models"
+	"exam-backend/oidc"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Single sign-on through the institution's OpenID provider (authorization code
+// flow with PKCE). GET /api/auth/oidc/login sends the browser to the provider,
+// which returns it to GET /api/auth/oidc/callback. The callback maps the ID token
+// to a User and redirects to the frontend with a one-time sso_code, and
+// POST /api/auth/oidc/exchange trades that code for the same response as Login.
+//
+// OIDC_ISSUER, OIDC_CLIENT_ID, OIDC_CLIENT_SECRET: SSO is off while OIDC_ISSUER is empty
+// OIDC_REDIRECT_URL: this server's callback (default http://localhost:8080/api/auth/oidc/callback)
+// OIDC_SCOPES: default "openid email profile"
+// OIDC_FRONTEND_URL: where the callback sends the browser (default APP_URL)
+// OIDC_GROUPS_CLAIM: claim listing the user's groups (default "groups")
+// OIDC_ROLE_MAP: "group=role,…", e.g. "exam-admins=admin,faculty=teacher,invigilators=proctor"
+// OIDC_DEFAULT_ROLE: role when no group maps (default student; "none" refuses the login)
+// OIDC_AUTO_CREATE=false: only users that already have an account may sign in
+// OIDC_SYNC_ROLES=false: apply mapped roles on first login only
+// OIDC_TRUST_EMAIL=true: link to an existing account by email even without email_verified
+// OIDC_MFA_AMR: amr values that count as a second factor (default "mfa,otp,hwk")
+
+const (
+	oidcStateTTL     = 10 * time.Minute
+	oidcLoginCodeTTL = time.Minute
+	oidcStateCookie  = "oidc_state"
+)
+
+var (
+	errSSONoEmail         = errors.New("sso_email_missing")
+	errSSOEmailUnverified = errors.New("sso_email_unverified")
+	errSSONoAccount       = errors.New("sso_account_not_found")
+	errSSONoRole          = errors.New("sso_no_matching_role")
+)
+
+type oidcState struct {
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+type oidcLogin struct {
+	UserID string `json:"user_id"`
+	MFA    bool   `json:"mfa"`
+}
+
+func oidcStateKey(state string) string { return "oidc_state:" + hashToken(state) }
+func oidcLoginKey(code string) string  { return "oidc_login:" + hashToken(code) }
+
+var (
+	oidcOnce     sync.Once
+	oidcProvider *oidc.Provider
+)
+
+// ssoProvider returns the configured provider, or nil when SSO is off.
+func ssoProvider() *oidc.Provider {
+	oidcOnce.Do(func() {
+		issuer := os.Getenv("OIDC_ISSUER")
+		if issuer == "" {
+			return
+		}
+		redirect := os.Getenv("OIDC_REDIRECT_URL")
+		if redirect == "" {
+			redirect = "http://localhost:8080/api/auth/oidc/callback"
+		}
+		oidcProvider = oidc.NewProvider(oidc.Config{
+			Issuer:       issuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  redirect,
+			Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+		})
+	})
+	return oidcProvider
+}
+
+func envBool(name string, def bool) bool {
+	switch strings.ToLower(os.Getenv(name)) {
+	case "true", "1", "yes":
+		return true
+	case "false", "0", "no":
+		return false
+	}
+	return def
+}
+
+func ssoFrontendURL(param, value string) string {
+	base := os.Getenv("OIDC_FRONTEND_URL")
+	if base == "" {
+		base = appBaseURL() + "/"
+	}
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + param + "=" + url.QueryEscape(value)
+}
+
+func claimString(claims jwt.MapClaims, name string) string {
+	v, _ := claims[name].(string)
+	return strings.TrimSpace(v)
+}
+
+// claimStrings reads a claim that providers send either as a list or a single string.
+func claimStrings(claims jwt.MapClaims, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func ssoEmailVerified(claims jwt.MapClaims) bool {
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		if v {
+			return true
+		}
+	case string: // some providers send "true"
+		if strings.EqualFold(v, "true") {
+			return true
+		}
+	}
+	return envBool("OIDC_TRUST_EMAIL", false)
+}
+
+func ssoDisplayName(claims jwt.MapClaims) string {
+	if name := claimString(claims, "name"); name != "" {
+		return name
+	}
+	if name := strings.TrimSpace(claimString(claims, "given_name") + " " + claimString(claims, "family_name")); name != "" {
+		return name
+	}
+	return claimString(claims, "preferred_username")
+}
+
+// ssoMFASatisfied reports whether the provider says it checked a second factor.
+func ssoMFASatisfied(claims jwt.MapClaims) bool {
+	accepted := os.Getenv("OIDC_MFA_AMR")
+	if accepted == "" {
+		accepted = "mfa,otp,hwk"
+	}
+	for _, amr := range claimStrings(claims, "amr") {
+		for _, ok := range strings.Split(accepted, ",") {
+			if strings.EqualFold(amr, strings.TrimSpace(ok)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// primary role order: the most privileged built-in wins, custom roles rank
+// above student so a "grader" group is not hidden behind it
+var ssoRoleRank = map[string]int{
+	models.RoleAdmin:   0,
+	models.RoleTeacher: 1,
+	models.RoleProctor: 2,
+	models.RoleStudent: 4,
+}
+
+func roleRank(name string) int {
+	if r, ok := ssoRoleRank[name]; ok {
+		return r
+	}
+	return 3
+}
+
+// ssoMappedRoles maps the groups claim through OIDC_ROLE_MAP, most privileged
+// role first. Unknown roles are skipped.
+func ssoMappedRoles(tx *gorm.DB, claims jwt.MapClaims) []string {
+	mapping := map[string][]string{}
+	for _, pair := range strings.Split(os.Getenv("OIDC_ROLE_MAP"), ",") {
+		group, role, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		mapping[group] = append(mapping[group], role)
+	}
+
+	groupsClaim := os.Getenv("OIDC_GROUPS_CLAIM")
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+
+	seen := map[string]bool{}
+	var roles []string
+	for _, group := range claimStrings(claims, groupsClaim) {
+		for _, role := range mapping[group] {
+			if seen[role] {
+				continue
+			}
+			seen[role] = true
+			if !roleExists(tx, role) {
+				log.Printf("OIDC_ROLE_MAP: role %q for group %q does not exist", role, group)
+				continue
+			}
+			roles = append(roles, role)
+		}
+	}
+	sort.SliceStable(roles, func(i, j int) bool {
+		if roleRank(roles[i]) != roleRank(roles[j]) {
+			return roleRank(roles[i]) < roleRank(roles[j])
+		}
+		return roles[i] < roles[j]
+	})
+	return roles
+}
+
+func ssoDefaultRole() string {
+	role := os.Getenv("OIDC_DEFAULT_ROLE")
+	switch role {
+	case "":
+		return models.RoleStudent
+	case "none":
+		return ""
+	}
+	return role
+}
+
+// applySSORoles makes roles[0] the primary role and the rest extra assignments,
+// replacing whatever the user had. A primary change ends existing sessions, as
+// in SetUserRoles.
+func applySSORoles(tx *gorm.DB, user *models.User, roles []string) error {
+	if user.Role != roles[0] {
+		if err := tx.Model(user).Update("role", roles[0]).Error; err != nil {
+			return err
+		}
+		if _, err := revokeSessions(tx, "user_id = ? AND active = true", user.ID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserRole{}).Error; err != nil {
+		return err
+	}
+	for _, name := range roles[1:] {
+		var role models.Role
+		if err := tx.Where("name = ?", name).First(&role).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.UserRole{UserID: user.ID, RoleID: role.ID}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ssoUser finds the account behind a verified ID token: by linked identity,
+// then by verified email, else a new account when OIDC_AUTO_CREATE allows it.
+func ssoUser(issuer string, claims jwt.MapClaims) (models.User, bool, error) {
+	subject := claimString(claims, "sub")
+	email := normalizeEmail(claimString(claims, "email"))
+	if email == "" && strings.Contains(claimString(claims, "preferred_username"), "@") {
+		email = normalizeEmail(claimString(claims, "preferred_username"))
+	}
+	verified := ssoEmailVerified(claims)
+
+	var user models.User
+	rolesChanged := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		roles := ssoMappedRoles(tx, claims)
+		created := false
+
+		var identity models.UserIdentity
+		err := tx.Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error
+		switch {
+		case err == nil:
+			if err := tx.First(&user, "id = ?", identity.UserID).Error; err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if email == "" {
+				return errSSONoEmail
+			}
+			err := tx.Where("LOWER(email) = ?", strings.ToLower(email)).First(&user).Error
+			switch {
+			case err == nil:
+				// linking on an unverified address would let anyone who can
+				// set that address at the provider take over the account
+				if !verified {
+					return errSSOEmailUnverified
+				}
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				if !envBool("OIDC_AUTO_CREATE", true) {
+					return errSSONoAccount
+				}
+				role := ssoDefaultRole()
+				if len(roles) > 0 {
+					role = roles[0]
+				}
+				if role == "" {
+					return errSSONoRole
+				}
+				user = models.User{Email: email, FullName: ssoDisplayName(claims), Role: role}
+				if verified {
+					now := time.Now()
+					user.EmailVerifiedAt = &now
+				}
+				if err := tx.Create(&user).Error; err != nil {
+					return err
+				}
+				created = true
+			default:
+				return err
+			}
+			identity = models.UserIdentity{UserID: user.ID, Issuer: issuer, Subject: subject}
+			if err := tx.Create(&identity).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		if len(roles) == 0 && ssoDefaultRole() == "" {
+			return errSSONoRole
+		}
+		if len(roles) > 0 && (created || envBool("OIDC_SYNC_ROLES", true)) {
+			if err := applySSORoles(tx, &user, roles); err != nil {
+				return err
+			}
+			rolesChanged = true
+		}
+
+		now := time.Now()
+		return tx.Model(&identity).Updates(map[string]interface{}{"email": email, "last_login_at": now}).Error
+	})
+	return user, rolesChanged, err
+}
+
+// GET /api/auth/oidc
+// Lets the login page decide whether to show the SSO button.
+func GetSSOConfig(c *gin.Context) {
+	if ssoProvider() == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": true, "login_url": "/api/auth/oidc/login"})
+}
+
+// GET /api/auth/oidc/login
+func SSOLogin(c *gin.Context) {
+	provider := ssoProvider()
+	if provider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sso_not_configured"})
+		return
+	}
+
+	var tokens [3]string
+	for i := range tokens {
+		t, err := randomToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start SSO"})
+			return
+		}
+		tokens[i] = t
+	}
+	state, nonce, verifier := tokens[0], tokens[1], tokens[2]
+
+	ctx := c.Request.Context()
+	if err := database.RedisSetJSON(ctx, oidcStateKey(state), oidcState{Nonce: nonce, Verifier: verifier}, oidcStateTTL); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start SSO"})
+		return
+	}
+	target, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		log.Printf("oidc login: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "sso_provider_unavailable"})
+		return
+	}
+	// binds the callback to this browser, so a callback URL from someone else's
+	// login cannot sign the victim into the attacker's account
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int(oidcStateTTL.Seconds()), "/api/auth/oidc", "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, target)
+}
+
+// GET /api/auth/oidc/callback?code=…&state=…
+// Browser-facing: every outcome is a redirect to the frontend, carrying either
+// sso_code or sso_error.
+func SSOCallback(c *gin.Context) {
+	provider := ssoProvider()
+	if provider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sso_not_configured"})
+		return
+	}
+	fail := func(code string) { c.Redirect(http.StatusFound, ssoFrontendURL("sso_error", code)) }
+
+	state := c.Query("state")
+	cookie, _ := c.Cookie(oidcStateCookie)
+	c.SetCookie(oidcStateCookie, "", -1, "/api/auth/oidc", "", c.Request.TLS != nil, true)
+	if state == "" || cookie != state {
+		fail("sso_state_invalid")
+		return
+	}
+
+	ctx := c.Request.Context()
+	raw, err := database.RedisGetDel(ctx, oidcStateKey(state))
+	var st oidcState
+	if err != nil || json.Unmarshal([]byte(raw), &st) != nil {
+		fail("sso_state_invalid")
+		return
+	}
+	if idpErr := c.Query("error"); idpErr != "" {
+		log.Printf("oidc callback: provider returned %s: %s", idpErr, c.Query("error_description"))
+		fail("sso_denied")
+		return
+	}
+
+	idToken, err := provider.Exchange(ctx, c.Query("code"), st.Verifier)
+	if err != nil {
+		log.Printf("oidc callback: %v", err)
+		fail("sso_exchange_failed")
+		return
+	}
+	claims, err := provider.VerifyIDToken(ctx, idToken, st.Nonce)
+	if err != nil {
+		log.Printf("oidc callback: %v", err)
+		fail("sso_token_invalid")
+		return
+	}
+
+	user, rolesChanged, err := ssoUser(os.Getenv("OIDC_ISSUER"), claims)
+	switch {
+	case errors.Is(err, errSSONoEmail), errors.Is(err, errSSOEmailUnverified),
+		errors.Is(err, errSSONoAccount), errors.Is(err, errSSONoRole):
+		fail(err.Error())
+		return
+	case err != nil:
+		log.Printf("oidc callback: %v", err)
+		fail("sso_failed")
+		return
+	}
+	if rolesChanged {
+		middleware.InvalidatePermissions(ctx)
+	}
+
+	code, err := randomToken()
+	if err == nil {
+		err = database.RedisSetJSON(ctx, oidcLoginKey(code), oidcLogin{UserID: user.ID.String(), MFA: ssoMFASatisfied(claims)}, oidcLoginCodeTTL)
+	}
+	if err != nil {
+		fail("sso_failed")
+		return
+	}
+	c.Redirect(http.StatusFound, ssoFrontendURL("sso_code", code))
+}
+
+// POST /api/auth/oidc/exchange
+// {"code": "<sso_code>", "fingerprint": "..."}: answers exactly like Login,
+// including the TOTP step for enrolled users the provider did not already
+// challenge for a second factor.
+func SSOExchange(c *gin.Context) {
+	var req struct {
+		Code        string `json:"code" binding:"required"`
+		Fingerprint string `json:"fingerprint"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+
+	var login oidcLogin
+	raw, err := database.RedisGetDel(c.Request.Context(), oidcLoginKey(req.Code))
+	if err != nil || json.Unmarshal([]byte(raw), &login) != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_or_expired_code"})
+		return
+	}
+	userID, err := uuid.Parse(login.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_or_expired_code"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_or_expired_code"})
+		return
+	}
+
+	if user.MFAEnabled && !login.MFA {
+		challenge, err := createMFAChallenge(c, user, req.Fingerprint)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start MFA"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    challenge,
+			"expires_in":   int(mfaChallengeTTL.Seconds()),
+		})
+		return
+	}
+
+	completeLogin(c, user, req.Fingerprint, login.MFA)
+}
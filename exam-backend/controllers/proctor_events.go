@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"exam-backend/database"
+	"exam-backend/models"
+	"time"
+)
+
+// Attempt status changes are published on a per-exam Redis channel so that a
+// proctor socket on any backend replica sees events from every replica.
+
+type ProctorEvent struct {
+	Type      string                 `json:"type"` // connected, disconnected, heartbeat, violation, flagged, autosave, started, submitted, terminated, paused, resumed
+	ExamID    string                 `json:"exam_id"`
+	AttemptID string                 `json:"attempt_id"`
+	At        time.Time              `json:"at"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+func proctorChannel(examID string) string {
+	return "proctor:exam:" + examID
+}
+
+func attemptExamKey(attemptID string) string {
+	return "attempt:exam:" + attemptID
+}
+
+func lastBeatKey(attemptID string) string {
+	return "ws_beat:" + attemptID
+}
+
+// rememberAttemptExam caches attempt -> exam so Redis-only paths (UpdateProgress) can publish.
+func rememberAttemptExam(ctx context.Context, attemptID, examID string) {
+	_ = database.RedisSet(ctx, attemptExamKey(attemptID), examID, 6*time.Hour)
+}
+
+// examIDForAttempt resolves the exam id from the cache, falling back to the DB.
+func examIDForAttempt(ctx context.Context, attemptID string) string {
+	if examID, err := database.RedisGet(ctx, attemptExamKey(attemptID)); err == nil && examID != "" {
+		return examID
+	}
+
+	var attempt models.ExamAttempt
+	if err := database.DB.Select("id", "exam_id").First(&attempt, "id = ?", attemptID).Error; err != nil {
+		return ""
+	}
+	examID := attempt.ExamID.String()
+	rememberAttemptExam(ctx, attemptID, examID)
+	return examID
+}
+
+// publishProctorEvent is best-effort: monitoring must never break the candidate path.
+func publishProctorEvent(ctx context.Context, examID, attemptID, eventType string, data map[string]interface{}) {
+	if examID == "" {
+		examID = examIDForAttempt(ctx, attemptID)
+		if examID == "" {
+			return
+		}
+	}
+
+	payload, err := json.Marshal(ProctorEvent{
+		Type:      eventType,
+		ExamID:    examID,
+		AttemptID: attemptID,
+		At:        time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return
+	}
+	_ = database.RedisPublish(ctx, proctorChannel(examID), string(payload))
+}
@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"exam-backend/database"
+	"exam-backend/middleware"
+	"exam-backend/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+type ProctorAttemptView struct {
+	AttemptID     uuid.UUID  `json:"attempt_id"`
+	StudentID     uuid.UUID  `json:"student_id"`
+	StudentName   string     `json:"student_name"`
+	StudentEmail  string     `json:"student_email"`
+	Status        string     `json:"status"` // in_progress, paused, submitted, terminated
+	Connected     bool       `json:"connected"`
+	LastHeartbeat *time.Time `json:"last_heartbeat"`
+	TabSwitches   int        `json:"tab_switches"`
+	Answered      int        `json:"answered"`
+	TimeLeft      int64      `json:"time_left"`
+	StartedAt     time.Time  `json:"started_at"`
+	SubmittedAt   *time.Time `json:"submitted_at"`
+}
+
+func attemptStatus(a models.ExamAttempt) string {
+	switch {
+	case a.IsTerminated:
+		return "terminated"
+	case a.SubmittedAt != nil:
+		return "submitted"
+	case a.PausedAt != nil:
+		return "paused"
+	default:
+		return "in_progress"
+	}
+}
+
+// buildProctorSnapshot merges DB state with the live Redis keys for every attempt of the exam.
+func buildProctorSnapshot(ctx context.Context, exam models.Exam) ([]ProctorAttemptView, error) {
+	var attempts []models.ExamAttempt
+	if err := database.DB.Preload("Student").Preload("Slot").
+		Where("exam_id = ?", exam.ID).
+		Order("started_at asc").
+		Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+
+	n := len(attempts)
+	keys := make([]string, 0, n*4)
+	for _, a := range attempts {
+		id := a.ID.String()
+		keys = append(keys, "ws_active:"+id, lastBeatKey(id), "attempt:tabs:"+id, "attempt:answers:"+id)
+	}
+	vals, err := database.RedisMGet(ctx, keys...)
+	if err != nil {
+		vals = make([]interface{}, len(keys))
+	}
+	str := func(i int) string {
+		if i >= len(vals) || vals[i] == nil {
+			return ""
+		}
+		s, _ := vals[i].(string)
+		return s
+	}
+
+	out := make([]ProctorAttemptView, 0, n)
+	for i, a := range attempts {
+		view := ProctorAttemptView{
+			AttemptID:    a.ID,
+			StudentID:    a.StudentID,
+			StudentName:  a.Student.FullName,
+			StudentEmail: a.Student.Email,
+			Status:       attemptStatus(a),
+			Connected:    str(i*4) != "",
+			TabSwitches:  a.TabSwitches,
+			Answered:     len(a.Answers),
+			StartedAt:    a.StartedAt,
+			SubmittedAt:  a.SubmittedAt,
+		}
+		if t, err := time.Parse(time.RFC3339, str(i*4+1)); err == nil {
+			view.LastHeartbeat = &t
+		}
+		if tabs, err := strconv.Atoi(str(i*4 + 2)); err == nil && tabs > view.TabSwitches {
+			view.TabSwitches = tabs
+		}
+		if raw := str(i*4 + 3); raw != "" {
+			var answers map[string]string
+			if json.Unmarshal([]byte(raw), &answers) == nil {
+				view.Answered = len(answers)
+			}
+		}
+		if view.Status == "in_progress" || view.Status == "paused" {
+			view.TimeLeft = computeTimeLeftSeconds(exam, a)
+		}
+		out = append(out, view)
+	}
+	return out, nil
+}
+
+// ProctorWebSocket streams live attempt status for one exam to an invigilator.
+// query params: exam_id, token (JWT; browsers cannot set headers on websockets)
+func ProctorWebSocket(c *gin.Context) {
+	examID, err := uuid.Parse(c.Query("exam_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_exam_id"})
+		return
+	}
+
+	token := c.Query("token")
+	if token == "" {
+		token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing token"})
+		return
+	}
+	claims, err := middleware.ValidateToken(token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if perms, err := middleware.UserPermissions(c.Request.Context(), claims.UserID); err != nil || !perms[models.PermProctorMonitor] {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission_denied", "permission": models.PermProctorMonitor})
+		return
+	}
+	if !claims.MFA {
+		if required, err := middleware.MFARequiredForUser(c.Request.Context(), claims.UserID); err != nil || required {
+			c.JSON(http.StatusForbidden, gin.H{"error": "mfa_required"})
+			return
+		}
+	}
+
+	var exam models.Exam
+	if err := database.DB.First(&exam, "id = ?", examID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Subscribe BEFORE taking the snapshot so no event falls in between.
+	sub := database.RedisSubscribe(ctx, proctorChannel(exam.ID.String()))
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "event_stream_unavailable"})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	ec := &examConn{conn: conn}
+
+	sendSnapshot := func() error {
+		attempts, err := buildProctorSnapshot(ctx, exam)
+		if err != nil {
+			return ec.writeJSON(gin.H{"type": "error", "error": "snapshot_failed"})
+		}
+		return ec.writeJSON(gin.H{
+			"type":        "snapshot",
+			"exam_id":     exam.ID,
+			"server_time": time.Now().UTC(),
+			"attempts":    attempts,
+		})
+	}
+	if err := sendSnapshot(); err != nil {
+		return
+	}
+
+	// Forward events until the socket or the subscription goes away.
+	go func() {
+		defer cancel()
+		ch := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				if err := ec.writeText(msg.Payload); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	heartbeatTimeout := 60 * time.Second
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(heartbeatTimeout))
+
+	for {
+		mt, message, err := conn.ReadMessage()
+		if err != nil || ctx.Err() != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(heartbeatTimeout))
+		if mt != websocket.TextMessage {
+			continue
+		}
+		switch string(message) {
+		case "ping", "heartbeat":
+			_ = ec.writeText("pong")
+		case "snapshot":
+			if err := sendSnapshot(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"exam-backend/database"
+	"exam-backend/middleware"
+	"exam-backend/models"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,63}$`)
+
+var builtInRoleDescriptions = map[string]string{
+	models.RoleStudent: "Takes exams",
+	models.RoleTeacher: "Maintains the question bank and class groups",
+	models.RoleAdmin:   "Full access",
+	models.RoleProctor: "Invigilates live exams",
+}
+
+var (
+	errRoleNotFound = errors.New("role_not_found")
+	errRoleLocked   = errors.New("admin_role_locked")
+	errRoleInUse    = errors.New("role_in_use")
+)
+
+type roleView struct {
+	models.Role
+	Permissions []string `json:"permissions"`
+	Users       int64    `json:"users"`
+}
+
+// SeedRoles creates the built-in roles on first start and keeps the admin role
+// holding every permission, including ones added in later releases.
+func SeedRoles() error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for name, perms := range models.DefaultRolePermissions {
+			var role models.Role
+			err := tx.Where("name = ?", name).First(&role).Error
+			created := false
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				role = models.Role{Name: name, Description: builtInRoleDescriptions[name], BuiltIn: true}
+				if err := tx.Create(&role).Error; err != nil {
+					return err
+				}
+				created = true
+			} else if err != nil {
+				return err
+			}
+			if !created && name != models.RoleAdmin {
+				continue
+			}
+			for _, p := range perms {
+				if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+					Create(&models.RolePermission{RoleID: role.ID, Permission: p}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		middleware.InvalidatePermissions(context.Background())
+	}
+	return err
+}
+
+func roleExists(tx *gorm.DB, name string) bool {
+	var n int64
+	tx.Model(&models.Role{}).Where("name = ?", name).Count(&n)
+	return n > 0
+}
+
+func validatePermissions(perms []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, p := range perms {
+		p = strings.TrimSpace(p)
+		if !models.IsPermission(p) {
+			return nil, errors.New("unknown permission: " + p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func setRolePermissions(tx *gorm.DB, roleID uuid.UUID, perms []string) error {
+	if err := tx.Where("role_id = ?", roleID).Delete(&models.RolePermission{}).Error; err != nil {
+		return err
+	}
+	for _, p := range perms {
+		if err := tx.Create(&models.RolePermission{RoleID: roleID, Permission: p}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadRoleView(role models.Role) roleView {
+	v := roleView{Role: role, Permissions: []string{}}
+	database.DB.Model(&models.RolePermission{}).Where("role_id = ?", role.ID).
+		Order("permission").Pluck("permission", &v.Permissions)
+	var primary, extra int64
+	database.DB.Model(&models.User{}).Where("role = ?", role.Name).Count(&primary)
+	database.DB.Model(&models.UserRole{}).Where("role_id = ?", role.ID).Count(&extra)
+	v.Users = primary + extra
+	return v
+}
+
+// GET /api/admin/permissions
+func ListPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, models.AllPermissions)
+}
+
+// GET /api/admin/roles
+func ListRoles(c *gin.Context) {
+	var roles []models.Role
+	database.DB.Order("built_in desc, name").Find(&roles)
+
+	out := make([]roleView, 0, len(roles))
+	for _, r := range roles {
+		out = append(out, loadRoleView(r))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// POST /api/admin/roles
+func CreateRole(c *gin.Context) {
+	var input struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.Name = strings.ToLower(strings.TrimSpace(input.Name))
+	if !roleNamePattern.MatchString(input.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must be 2-64 characters: lowercase letters, digits, '-' or '_'"})
+		return
+	}
+	perms, err := validatePermissions(input.Permissions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role := models.Role{Name: input.Name, Description: input.Description}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if roleExists(tx, role.Name) {
+			return errRoleInUse
+		}
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
+		return setRolePermissions(tx, role.ID, perms)
+	})
+	switch {
+	case errors.Is(err, errRoleInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": "role_exists"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+		return
+	}
+	middleware.InvalidatePermissions(c.Request.Context())
+	c.JSON(http.StatusCreated, loadRoleView(role))
+}
+
+// PUT /api/admin/roles/:id
+// Replaces description and/or permissions. Names are fixed because users.role
+// refers to them; the admin role cannot be changed so nobody locks themselves out.
+func UpdateRole(c *gin.Context) {
+	var input struct {
+		Description *string   `json:"description"`
+		Permissions *[]string `json:"permissions"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var perms []string
+	if input.Permissions != nil {
+		var err error
+		if perms, err = validatePermissions(*input.Permissions); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var role models.Role
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&role, "id = ?", c.Param("id")).Error; err != nil {
+			return errRoleNotFound
+		}
+		if role.Name == models.RoleAdmin {
+			return errRoleLocked
+		}
+		if input.Description != nil {
+			if err := tx.Model(&role).Update("description", *input.Description).Error; err != nil {
+				return err
+			}
+		}
+		if input.Permissions != nil {
+			return setRolePermissions(tx, role.ID, perms)
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errRoleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errRoleLocked):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	middleware.InvalidatePermissions(c.Request.Context())
+	c.JSON(http.StatusOK, loadRoleView(role))
+}
+
+// DELETE /api/admin/roles/:id
+// Only custom roles that are nobody's primary role; extra assignments go with it.
+func DeleteRole(c *gin.Context) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+		if err := tx.First(&role, "id = ?", c.Param("id")).Error; err != nil {
+			return errRoleNotFound
+		}
+		if role.BuiltIn {
+			return errRoleLocked
+		}
+		var primary int64
+		tx.Model(&models.User{}).Where("role = ?", role.Name).Count(&primary)
+		if primary > 0 {
+			return errRoleInUse
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+	switch {
+	case errors.Is(err, errRoleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errRoleLocked):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "built_in_role"})
+		return
+	case errors.Is(err, errRoleInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		return
+	}
+	middleware.InvalidatePermissions(c.Request.Context())
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted"})
+}
+
+// GET /api/admin/users/:id/roles
+func GetUserRoles(c *gin.Context) {
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	var extra []models.UserRole
+	database.DB.Preload("Role").Where("user_id = ?", user.ID).Find(&extra)
+
+	perms, err := middleware.UserPermissions(c.Request.Context(), user.ID.String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+		return
+	}
+	effective := make([]string, 0, len(perms))
+	for p := range perms {
+		effective = append(effective, p)
+	}
+	sort.Strings(effective)
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":     user.ID,
+		"role":        user.Role,
+		"roles":       extra,
+		"permissions": effective,
+	})
+}
+
+// PUT /api/admin/users/:id/roles
+// {"role": "teacher", "roles": ["proctor"]}: role (optional) replaces the primary
+// role, roles replaces the extra assignments. A primary role change ends the
+// user's sessions because the role travels in the access token.
+func SetUserRoles(c *gin.Context) {
+	var input struct {
+		Role  string    `json:"role"`
+		Roles *[]string `json:"roles"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Param("id") == c.GetString("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "cannot_change_own_roles"})
+		return
+	}
+	adminID, _ := uuid.Parse(c.GetString("userID"))
+
+	var user models.User
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, "id = ?", c.Param("id")).Error; err != nil {
+			return gorm.ErrRecordNotFound
+		}
+
+		if input.Role != "" && input.Role != user.Role {
+			if !roleExists(tx, input.Role) {
+				return errRoleNotFound
+			}
+			if err := tx.Model(&user).Update("role", input.Role).Error; err != nil {
+				return err
+			}
+			if _, err := revokeSessions(tx, "user_id = ? AND active = true", user.ID); err != nil {
+				return err
+			}
+		}
+
+		if input.Roles != nil {
+			if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserRole{}).Error; err != nil {
+				return err
+			}
+			for _, name := range *input.Roles {
+				var role models.Role
+				if err := tx.Where("name = ?", name).First(&role).Error; err != nil {
+					return errRoleNotFound
+				}
+				if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.UserRole{
+					UserID:       user.ID,
+					RoleID:       role.ID,
+					AssignedByID: adminID,
+				}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	case errors.Is(err, errRoleNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update roles"})
+		return
+	}
+	middleware.InvalidatePermissions(c.Request.Context())
+	GetUserRoles(c)
+}
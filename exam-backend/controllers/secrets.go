@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"errors"
+	"os"
+)
+
+// InitSecrets refuses to start without the shared secrets that key the email
+// links (JWT_SECRET) and the TOTP secrets at rest (MFA_ENCRYPTION_KEY, falling
+// back to JWT_SECRET). Both used to derive from an empty string when unset,
+// which makes reset links forgeable and the sealed secrets readable.
+func InitSecrets() error {
+	if os.Getenv("MFA_ENCRYPTION_KEY") == "" && os.Getenv("JWT_SECRET") == "" {
+		return errors.New("neither MFA_ENCRYPTION_KEY nor JWT_SECRET is set")
+	}
+	if os.Getenv("JWT_SECRET") == "" {
+		return errors.New("JWT_SECRET is not set (it signs password reset and email verification links)")
+	}
+	return nil
+}
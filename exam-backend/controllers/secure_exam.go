@@ -3,6 +3,7 @@ package controllers
 import (
 	"errors"
 	"exam-backend/database"
+	"exam-backend/middleware"
 	"exam-backend/models"
 	"math"
 	"net/http"
@@ -16,13 +17,6 @@ import (
 	"gorm.io/gorm"
 )
 
-// Keep IST location as you had
-var istLocation, _ = time.LoadLocation("Asia/Kolkata")
-
-func nowIST() time.Time {
-	return time.Now().In(istLocation)
-}
-
 // ------------------------- Sanitizer (student-facing) -------------------------
 func sanitizeQuestions(questions []models.Question) []gin.H {
 	clean := make([]gin.H, 0, len(questions))
@@ -59,6 +53,21 @@ func GetExamDetails(c *gin.Context) {
 		return
 	}
 
+	if !middleware.HasPermission(c, models.PermExamRead) {
+		allowed, err := studentCanAccessExam(exam.ID, c.GetString("userID"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check exam assignment"})
+			return
+		}
+		if !allowed {
+			// same response as a missing exam: don't leak other groups' exams
+			c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+			return
+		}
+	}
+
+	localizeExam(&exam)
+
 	// Student-facing sanitized payload (no correct answers or points)
 	resp := gin.H{
 		"id":               exam.ID,
@@ -67,6 +76,7 @@ func GetExamDetails(c *gin.Context) {
 		"duration_minutes": exam.DurationMinutes,
 		"start_time":       exam.StartTime,
 		"end_time":         exam.EndTime,
+		"time_zone":        exam.TimeZone,
 		"is_active":        exam.IsActive,
 		"section_locking":  exam.SectionLocking,
 		"questions":        sanitizeQuestions(exam.Questions),
@@ -80,6 +90,7 @@ func StartAttempt(c *gin.Context) {
 	var input struct {
 		ExamID      string `json:"exam_id"`
 		Fingerprint string `json:"fingerprint,omitempty"`
+		AccessCode  string `json:"access_code,omitempty"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
@@ -97,21 +108,12 @@ func StartAttempt(c *gin.Context) {
 		return
 	}
 
-	// simple scheduling checks
-	now := time.Now().In(istLocation)
-	if !exam.StartTime.IsZero() && now.Before(exam.StartTime) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "exam_not_started", "start_time": exam.StartTime})
+	// centre-based exams: only from the allowed networks
+	if !clientIPAllowed(exam, c.ClientIP()) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "ip_not_allowed", "message": "This exam can only be taken from an approved exam centre network."})
 		return
 	}
-	if !exam.EndTime.IsZero() && now.After(exam.EndTime) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "exam_closed"})
-		return
-	}
-	// user can not start exam after 5 min of delay and no attempt active
-	// if !exam.StartTime.IsZero() && now.After(exam.StartTime.Add(5*time.Minute)) {
-	// 	c.JSON(http.StatusBadRequest, gin.H{"error": "exam_delayed"})
-	// 	return
-	// }
+
 	uidVal, exists := c.Get("userID")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not in context"})
@@ -124,6 +126,38 @@ func StartAttempt(c *gin.Context) {
 		return
 	}
 
+	allowed, err := studentCanAccessExam(exam.ID, userIDStr)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error checking exam assignment"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "exam_not_assigned", "message": "This exam is not assigned to your group."})
+		return
+	}
+
+	// Multi-slot exams run in the student's booked slot window instead of the exam's
+	slot, hasSlots, err := studentSlot(exam.ID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error checking slot booking"})
+		return
+	}
+	windowStart, windowEnd := exam.StartTime, exam.EndTime
+	if hasSlots {
+		if slot == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "slot_not_booked", "message": "You are not booked into a slot for this exam."})
+			return
+		}
+		windowStart, windowEnd = slot.StartTime, slot.EndTime
+	}
+
+	now := time.Now()
+	// user can not start exam after 5 min of delay and no attempt active
+	// if !exam.StartTime.IsZero() && now.After(exam.StartTime.Add(5*time.Minute)) {
+	// 	c.JSON(http.StatusBadRequest, gin.H{"error": "exam_delayed"})
+	// 	return
+	// }
+
 	// Use a DB transaction to check and create atomically
 	tx := database.DB.Begin()
 	defer func() {
@@ -134,11 +168,19 @@ func StartAttempt(c *gin.Context) {
 
 	// 1) Look for an existing ACTIVE attempt to RESUME (unsubmitted + not terminated)
 	var existing models.ExamAttempt
-	if err := tx.
+	if err := tx.Preload("Slot").
 		Where("student_id = ? AND exam_id = ? AND submitted_at IS NULL AND is_terminated = false", userID, examUUID).
 		Order("started_at desc").
 		First(&existing).Error; err == nil {
 
+		// A resumed attempt runs to its own deadline, which proctor pauses push past
+		// the window; a paused attempt cannot expire until it is resumed.
+		if deadline := attemptDeadline(exam, existing); existing.PausedAt == nil && !deadline.IsZero() && now.After(deadline) {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "exam_closed"})
+			return
+		}
+
 		// Resume existing attempt: return its id and reissue current exam_token if missing
 		if existing.ExamToken == "" {
 			existing.ExamToken = uuid.New().String()
@@ -147,6 +189,8 @@ func StartAttempt(c *gin.Context) {
 		}
 		tx.Commit()
 
+		rememberAttemptExam(c.Request.Context(), existing.ID.String(), exam.ID.String())
+
 		c.JSON(http.StatusOK, gin.H{
 			"id":           existing.ID,
 			"exam_id":      existing.ExamID,
@@ -155,6 +199,8 @@ func StartAttempt(c *gin.Context) {
 			"exam_token":   existing.ExamToken,
 			"answers":      existing.Answers,
 			"tab_switches": existing.TabSwitches,
+			"paused":       existing.PausedAt != nil,
+			"answer_seq":   storedAnswerSeq(c.Request.Context(), existing.ID.String()),
 			"status":       "resumed",
 		})
 		return
@@ -179,6 +225,26 @@ func StartAttempt(c *gin.Context) {
 	}
 
 	// 3) Create new attempt (Only if user has NEVER touched this exam before)
+
+	// simple scheduling checks: the window only limits starting a new attempt
+	if !windowStart.IsZero() && now.Before(windowStart) {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "exam_not_started", "start_time": windowStart})
+		return
+	}
+	if !windowEnd.IsZero() && now.After(windowEnd) {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "exam_closed"})
+		return
+	}
+
+	// Access code is only required to START; resuming after a crash must keep working
+	// even if the invigilator rotated the code in the meantime.
+	if exam.AccessCode != "" && !accessCodeMatches(exam.AccessCode, input.AccessCode) {
+		tx.Rollback()
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid_access_code", "message": "A valid access code is required to start this exam."})
+		return
+	}
 	
 	// Generate ID and Token BEFORE creating
 	newID := uuid.New()
@@ -194,6 +260,9 @@ func StartAttempt(c *gin.Context) {
 		Answers:     map[string]string{},
 		Snapshots:   []string{},
 	}
+	if slot != nil {
+		attempt.SlotID = &slot.ID
+	}
 
 	// Single DB call (Create) containing the token and ID
 	if err := tx.Create(&attempt).Error; err != nil {
@@ -205,12 +274,19 @@ func StartAttempt(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start exam"})
 		return
 	}
+	// set after Create so GORM doesn't upsert the slot; time_left needs the slot's end
+	attempt.Slot = slot
 
 	// best-effort: store exam token in redis for fast checks
 	_ = database.RedisSet(c.Request.Context(), "attempt_session:"+attempt.ID.String(), examToken, 2*time.Hour)
 
 	tx.Commit()
 
+	rememberAttemptExam(c.Request.Context(), attempt.ID.String(), exam.ID.String())
+	publishProctorEvent(c.Request.Context(), exam.ID.String(), attempt.ID.String(), "started", map[string]interface{}{
+		"student_id": userID,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"id":         attempt.ID,
 		"exam_id":    attempt.ExamID,
@@ -228,18 +304,23 @@ func computeTimeLeftSeconds(exam models.Exam, attempt models.ExamAttempt) int64
 		return 0
 	}
 
-	// 1. Calculate when the attempt MUST end based on duration
-	attemptExpiry := attempt.StartedAt.Add(time.Duration(exam.DurationMinutes) * time.Minute)
+	// 1. Calculate when the attempt MUST end based on duration (+ time spent paused)
+	paused := time.Duration(attempt.PausedSeconds) * time.Second
+	attemptExpiry := attempt.StartedAt.Add(time.Duration(exam.DurationMinutes)*time.Minute + paused)
 
 	// 2. If the Exam has a hard global deadline (e.g. closes at 5:00 PM), respect it
 	// (Only if EndTime is set and is earlier than the attempt expiry)
-	if !exam.EndTime.IsZero() && attemptExpiry.After(exam.EndTime) {
-		attemptExpiry = exam.EndTime
+	deadline := attemptDeadline(exam, attempt)
+	if !deadline.IsZero() && attemptExpiry.After(deadline) {
+		attemptExpiry = deadline
 	}
 
-	// 3. Calculate remaining seconds from NOW
-	// We use time.Until(attemptExpiry) which is equivalent to attemptExpiry.Sub(time.Now())
-	left := int64(time.Until(attemptExpiry).Seconds())
+	// 3. Calculate remaining seconds from NOW (or from the moment the clock was paused)
+	reference := time.Now()
+	if attempt.PausedAt != nil {
+		reference = *attempt.PausedAt
+	}
+	left := int64(attemptExpiry.Sub(reference).Seconds())
 
 	if left < 0 {
 		return 0
@@ -247,6 +328,19 @@ func computeTimeLeftSeconds(exam models.Exam, attempt models.ExamAttempt) int64
 	return left
 }
 
+// attemptDeadline is the hard close for an attempt: its slot's end, or the exam's,
+// pushed back by completed proctor pauses.
+func attemptDeadline(exam models.Exam, attempt models.ExamAttempt) time.Time {
+	deadline := exam.EndTime
+	if attempt.Slot != nil {
+		deadline = attempt.Slot.EndTime
+	}
+	if deadline.IsZero() {
+		return deadline
+	}
+	return deadline.Add(time.Duration(attempt.PausedSeconds) * time.Second)
+}
+
 // ------------------------- AUTOSAVE / PROGRESS -------------------------
 // controllers/secure_exam.go
 
@@ -254,7 +348,7 @@ func UpdateProgress(c *gin.Context) {
 	var input struct {
 		AttemptID   string            `json:"attempt_id"`
 		Answers     map[string]string `json:"answers"`
-		Snapshot    string            `json:"snapshot"`
+		Snapshot    string            `json:"snapshot"` // ignored: upload to POST /api/attempts/:id/snapshots
 		TabSwitches int               `json:"tab_switches"`
 	}
 
@@ -266,6 +360,11 @@ func UpdateProgress(c *gin.Context) {
 	ctx := c.Request.Context()
 	attemptID := input.AttemptID
 
+	if isAttemptPaused(ctx, attemptID) {
+		c.JSON(http.StatusLocked, gin.H{"error": "attempt_paused"})
+		return
+	}
+
 	answersKey := "attempt:answers:" + attemptID
 	tabsKey := "attempt:tabs:" + attemptID
 	dirtyKey := "attempt:dirty:" + attemptID
@@ -283,6 +382,11 @@ func UpdateProgress(c *gin.Context) {
 	// 3️⃣ Mark attempt as dirty (needs DB flush)
 	_ = database.RedisSet(ctx, dirtyKey, "1", 3*time.Hour)
 
+	publishProctorEvent(ctx, "", attemptID, "autosave", map[string]interface{}{
+		"answered":     len(input.Answers),
+		"tab_switches": input.TabSwitches,
+	})
+
 	c.JSON(200, gin.H{"status": "ok"})
 }
 
@@ -304,7 +408,7 @@ func SubmitAttempt(c *gin.Context) {
 	}
 
 	var attempt models.ExamAttempt
-	if err := database.DB.Preload("Exam.Questions").First(&attempt, "id = ?", attemptUUID).Error; err != nil {
+	if err := database.DB.Preload("Exam.Questions").Preload("Slot").First(&attempt, "id = ?", attemptUUID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "attempt not found"})
 		return
 	}
@@ -345,9 +449,14 @@ func SubmitAttempt(c *gin.Context) {
 
 	attempt.Passed = percentage >= float64(attempt.Exam.PassingScore)
 
-	now := nowIST()
+	now := time.Now().UTC()
 	gracePeriod := time.Duration(2) * time.Minute
-	if !attempt.Exam.EndTime.IsZero() && now.After(attempt.Exam.EndTime.Add(gracePeriod)) {
+	deadline := attemptDeadline(attempt.Exam, attempt)
+	if attempt.PausedAt != nil && !deadline.IsZero() {
+		// submitted while paused: the running pause doesn't count against the candidate
+		deadline = deadline.Add(time.Since(*attempt.PausedAt))
+	}
+	if !deadline.IsZero() && now.After(deadline.Add(gracePeriod)) {
 		attempt.IsTerminated = true
 		attempt.TerminationReason = "Time limit exceeded (Server validation)"
 	}
@@ -358,6 +467,11 @@ func SubmitAttempt(c *gin.Context) {
 		return
 	}
 
+	publishProctorEvent(ctx, attempt.ExamID.String(), attemptID, "submitted", map[string]interface{}{
+		"score":         attempt.Score,
+		"is_terminated": attempt.IsTerminated,
+	})
+
 	// Return results (student sees score after submission)
 	c.JSON(http.StatusOK, gin.H{
 		"score":        attempt.Score,
@@ -377,12 +491,10 @@ func GetAttemptDetails(c *gin.Context) {
 		return
 	}
 
-	roleVal, _ := c.Get("role")
-	role, _ := roleVal.(string)
 	uidVal, _ := c.Get("userID")
 	userIDStr, _ := uidVal.(string)
 
-	if role != "admin" && role != "teacher" {
+	if !middleware.HasPermission(c, models.PermAttemptGrade) {
 		if attempt.SubmittedAt == nil || userIDStr != attempt.StudentID.String() {
 			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
 			return
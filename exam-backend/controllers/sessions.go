@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"exam-backend/database"
+	"exam-backend/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func sessionView(s models.UserSession, currentJti string) gin.H {
+	return gin.H{
+		"id":                 s.ID,
+		"ip":                 s.IP,
+		"device_fingerprint": s.DeviceFingerprint,
+		"active":             s.Active,
+		"created_at":         s.CreatedAt,
+		"expires_at":         s.ExpiresAt,
+		"current":            s.Jti == currentJti,
+	}
+}
+
+func listSessions(c *gin.Context, userID string, includeInactive bool) {
+	query := database.DB.Where("user_id = ?", userID)
+	if !includeInactive {
+		query = query.Where("active = true")
+	}
+	var sessions []models.UserSession
+	if err := query.Order("created_at desc").Limit(200).Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load sessions"})
+		return
+	}
+
+	out := make([]gin.H, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, sessionView(s, c.GetString("jti")))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// POST /api/auth/logout
+// Ends the current session; {"all": true} ends every session of the user.
+func Logout(c *gin.Context) {
+	var input struct {
+		All bool `json:"all"`
+	}
+	_ = c.ShouldBindJSON(&input)
+
+	var err error
+	if input.All {
+		_, err = revokeSessions(database.DB, "user_id = ?", c.GetString("userID"))
+	} else {
+		_, err = revokeSessions(database.DB, "jti = ?", c.GetString("jti"))
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// GET /api/auth/sessions
+func ListMySessions(c *gin.Context) {
+	listSessions(c, c.GetString("userID"), false)
+}
+
+// DELETE /api/auth/sessions/:id
+func RevokeMySession(c *gin.Context) {
+	ended, err := revokeSessions(database.DB, "id = ? AND user_id = ?", c.Param("id"), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	if ended == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// GET /api/admin/users/:id/sessions?all=true
+func AdminListUserSessions(c *gin.Context) {
+	var user models.User
+	if err := database.DB.Select("id").First(&user, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	listSessions(c, user.ID.String(), c.Query("all") == "true")
+}
+
+// DELETE /api/admin/users/:id/sessions
+func AdminRevokeUserSessions(c *gin.Context) {
+	ended, err := revokeSessions(database.DB, "user_id = ? AND active = true", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked", "revoked": ended})
+}
+
+// DELETE /api/admin/sessions/:id
+func AdminRevokeSession(c *gin.Context) {
+	ended, err := revokeSessions(database.DB, "id = ?", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	if ended == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or already revoked"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"exam-backend/database"
+	"exam-backend/models"
+	"exam-backend/storage"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Webcam snapshots are uploaded by the candidate's browser with the attempt's
+// exam_token and kept in storage.Snapshots; Postgres only stores metadata.
+
+const (
+	snapshotMaxBytes   = 2 << 20 // 2 MiB per image
+	snapshotThumbWidth = 160
+)
+
+// snapshotLimits reads SNAPSHOT_MAX_PER_ATTEMPT (default 300) and
+// SNAPSHOT_RETENTION_DAYS (default 90, 0 keeps snapshots forever).
+func snapshotLimits() (maxPerAttempt int, retention time.Duration) {
+	maxPerAttempt = 300
+	if v, err := strconv.Atoi(os.Getenv("SNAPSHOT_MAX_PER_ATTEMPT")); err == nil && v > 0 {
+		maxPerAttempt = v
+	}
+	days := 90
+	if v, err := strconv.Atoi(os.Getenv("SNAPSHOT_RETENTION_DAYS")); err == nil && v >= 0 {
+		days = v
+	}
+	return maxPerAttempt, time.Duration(days) * 24 * time.Hour
+}
+
+func deleteSnapshots(snaps []models.AttemptSnapshot) {
+	for _, s := range snaps {
+		if err := storage.Snapshots.Delete(context.Background(), s.StorageKey); err != nil {
+			log.Printf("snapshot %s: delete blob: %v", s.ID, err)
+			continue
+		}
+		_ = storage.Snapshots.Delete(context.Background(), s.ThumbKey)
+		database.DB.Delete(&models.AttemptSnapshot{}, "id = ?", s.ID)
+	}
+}
+
+// StartSnapshotRetentionTask removes snapshots older than the retention period.
+func StartSnapshotRetentionTask() {
+	ticker := time.NewTicker(1 * time.Hour)
+
+	go func() {
+		for range ticker.C {
+			_, retention := snapshotLimits()
+			if retention <= 0 {
+				continue
+			}
+			var old []models.AttemptSnapshot
+			database.DB.Where("created_at < ?", time.Now().Add(-retention)).Limit(1000).Find(&old)
+			deleteSnapshots(old)
+		}
+	}()
+}
+
+// POST /api/attempts/:id/snapshots
+// multipart: image (JPEG/PNG), token (exam_token; or X-Exam-Token header), captured_at (optional, RFC3339 or unix ms)
+func UploadSnapshot(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, snapshotMaxBytes+64<<10)
+
+	var attempt models.ExamAttempt
+	if err := database.DB.Select("id", "exam_id", "student_id", "exam_token", "submitted_at", "is_terminated").
+		First(&attempt, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attempt_not_found"})
+		return
+	}
+
+	token := c.GetHeader("X-Exam-Token")
+	if token == "" {
+		token = c.PostForm("token")
+	}
+	if attempt.ExamToken == "" || token != attempt.ExamToken || c.GetString("userID") != attempt.StudentID.String() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+	if attempt.SubmittedAt != nil || attempt.IsTerminated {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "attempt_already_finalized"})
+		return
+	}
+
+	file, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image is required"})
+		return
+	}
+	if file.Size > snapshotMaxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "image_too_large"})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read image"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(f, snapshotMaxBytes+1))
+	f.Close()
+	if err != nil || len(data) > snapshotMaxBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read image"})
+		return
+	}
+
+	contentType := http.DetectContentType(data)
+	ext := ""
+	switch contentType {
+	case "image/jpeg":
+		ext = ".jpg"
+	case "image/png":
+		ext = ".png"
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "image must be JPEG or PNG"})
+		return
+	}
+
+	thumb, width, height, err := storage.Thumbnail(data, snapshotThumbWidth)
+	if errors.Is(err, storage.ErrImageTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "image_too_large"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_image"})
+		return
+	}
+
+	snap := models.AttemptSnapshot{
+		ID:          uuid.New(),
+		AttemptID:   attempt.ID,
+		ExamID:      attempt.ExamID,
+		ContentType: contentType,
+		SizeBytes:   int64(len(data)),
+		Width:       width,
+		Height:      height,
+		CapturedAt:  parseClientTime(clientTimeValue(c.PostForm("captured_at"))),
+	}
+	prefix := fmt.Sprintf("snapshots/%s/%s/%s", attempt.ExamID, attempt.ID, snap.ID)
+	snap.StorageKey = prefix + ext
+	snap.ThumbKey = prefix + "_thumb.jpg"
+
+	ctx := c.Request.Context()
+	if err := storage.Snapshots.Put(ctx, snap.StorageKey, contentType, bytes.NewReader(data)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store snapshot"})
+		return
+	}
+	if err := storage.Snapshots.Put(ctx, snap.ThumbKey, "image/jpeg", bytes.NewReader(thumb)); err != nil {
+		_ = storage.Snapshots.Delete(ctx, snap.StorageKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store snapshot"})
+		return
+	}
+	if err := database.DB.Create(&snap).Error; err != nil {
+		_ = storage.Snapshots.Delete(ctx, snap.StorageKey)
+		_ = storage.Snapshots.Delete(ctx, snap.ThumbKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save snapshot"})
+		return
+	}
+
+	// per-attempt cap: drop the oldest beyond the limit
+	maxPerAttempt, _ := snapshotLimits()
+	var excess []models.AttemptSnapshot
+	database.DB.Where("attempt_id = ?", attempt.ID).
+		Order("created_at desc").
+		Offset(maxPerAttempt).
+		Find(&excess)
+	deleteSnapshots(excess)
+
+	publishProctorEvent(ctx, attempt.ExamID.String(), attempt.ID.String(), "snapshot", map[string]interface{}{
+		"snapshot_id": snap.ID,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":         snap.ID,
+		"created_at": snap.CreatedAt,
+	})
+}
+
+// clientTimeValue lets captured_at be either unix milliseconds or RFC3339.
+func clientTimeValue(raw string) interface{} {
+	if ms, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return float64(ms)
+	}
+	return raw
+}
+
+// GET /api/admin/attempts/:id/snapshots?from=RFC3339&to=RFC3339
+func ListAttemptSnapshots(c *gin.Context) {
+	query := database.DB.Where("attempt_id = ?", c.Param("id"))
+	for param, cond := range map[string]string{"from": "created_at >= ?", "to": "created_at <= ?"} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be RFC3339"})
+			return
+		}
+		query = query.Where(cond, t.UTC())
+	}
+
+	var snaps []models.AttemptSnapshot
+	if err := query.Order("created_at asc").Find(&snaps).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load snapshots"})
+		return
+	}
+
+	out := make([]gin.H, 0, len(snaps))
+	for _, s := range snaps {
+		out = append(out, gin.H{
+			"snapshot":      s,
+			"image_url":     "/api/admin/snapshots/" + s.ID.String() + "/image",
+			"thumbnail_url": "/api/admin/snapshots/" + s.ID.String() + "/thumbnail",
+		})
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func serveSnapshotBlob(c *gin.Context, thumbnail bool) {
+	var snap models.AttemptSnapshot
+	if err := database.DB.First(&snap, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "snapshot not found"})
+		return
+	}
+
+	key, contentType := snap.StorageKey, snap.ContentType
+	if thumbnail {
+		key, contentType = snap.ThumbKey, "image/jpeg"
+	}
+
+	blob, err := storage.Snapshots.Get(c.Request.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "snapshot image missing"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read snapshot"})
+		return
+	}
+	defer blob.Close()
+
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.DataFromReader(http.StatusOK, -1, contentType, blob, nil)
+}
+
+// GET /api/admin/snapshots/:id/image
+func GetSnapshotImage(c *gin.Context) {
+	serveSnapshotBlob(c, false)
+}
+
+// GET /api/admin/snapshots/:id/thumbnail
+func GetSnapshotThumbnail(c *gin.Context) {
+	serveSnapshotBlob(c, true)
+}
@@ -0,0 +1,303 @@
+package controllers
+
+import (
+	"errors"
+	"exam-backend/database"
+	"exam-backend/middleware"
+	"exam-backend/models"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Self-registration only creates students. Teachers and admins are created by an
+// admin directly or accept an invite sent by one.
+//
+// SELF_REGISTRATION: "students" (default) or "disabled"
+// STUDENT_EMAIL_DOMAINS: comma-separated allowlist, e.g. "college.edu,alumni.college.edu" (empty = any)
+
+const defaultInviteTTL = 72 * time.Hour
+
+// staffRoleAllowed checks a non-student role for a new account. Handing out staff
+// roles needs role:manage on top of user:manage, so user managers cannot mint admins.
+func staffRoleAllowed(c *gin.Context, role string) (bool, string) {
+	if role == models.RoleStudent || !roleExists(database.DB, role) {
+		return false, "unknown or non-staff role"
+	}
+	if !middleware.HasPermission(c, models.PermRoleManage) {
+		return false, "assigning staff roles requires " + models.PermRoleManage
+	}
+	return true, ""
+}
+
+var (
+	errInviteInvalid = errors.New("invalid_or_expired_invite")
+	errEmailTaken    = errors.New("email_already_registered")
+)
+
+func selfRegistrationDisabled() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("SELF_REGISTRATION")), "disabled")
+}
+
+func normalizeEmail(email string) string {
+	return strings.TrimSpace(email)
+}
+
+func studentEmailAllowed(email string) bool {
+	raw := strings.TrimSpace(os.Getenv("STUDENT_EMAIL_DOMAINS"))
+	if raw == "" {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, d := range strings.Split(raw, ",") {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" && domain == d {
+			return true
+		}
+	}
+	return false
+}
+
+// POST /api/admin/users
+func AdminCreateUser(c *gin.Context) {
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+		FullName string `json:"full_name"`
+		Role     string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.Email = normalizeEmail(input.Email)
+	if input.Email == "" || !strings.Contains(input.Email, "@") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "valid email is required"})
+		return
+	}
+	if input.Role != models.RoleStudent {
+		if ok, msg := staffRoleAllowed(c, input.Role); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+	}
+	if len(input.Password) < 6 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 6 characters"})
+		return
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	user := models.User{
+		Email:    input.Email,
+		Password: string(hashed),
+		FullName: input.FullName,
+		Role:     input.Role,
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not create user. Email might already exist."})
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// POST /api/admin/invites
+func CreateStaffInvite(c *gin.Context) {
+	var input struct {
+		Email          string `json:"email"`
+		Role           string `json:"role"`
+		ExpiresInHours int    `json:"expires_in_hours"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.Email = normalizeEmail(input.Email)
+	if input.Email == "" || !strings.Contains(input.Email, "@") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "valid email is required"})
+		return
+	}
+	if ok, msg := staffRoleAllowed(c, input.Role); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var existing int64
+	database.DB.Model(&models.User{}).Where("email = ?", input.Email).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": errEmailTaken.Error()})
+		return
+	}
+
+	ttl := defaultInviteTTL
+	if input.ExpiresInHours > 0 {
+		ttl = time.Duration(input.ExpiresInHours) * time.Hour
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+	adminID, _ := uuid.Parse(c.GetString("userID"))
+	invite := models.UserInvite{
+		Email:       input.Email,
+		Role:        input.Role,
+		TokenHash:   hashToken(token),
+		InvitedByID: adminID,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+	if err := database.DB.Create(&invite).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+
+	// the token is only shown once; hand it to the invitee out of band
+	c.JSON(http.StatusCreated, gin.H{
+		"invite": invite,
+		"token":  token,
+	})
+}
+
+// GET /api/admin/invites
+func ListInvites(c *gin.Context) {
+	var invites []models.UserInvite
+	if err := database.DB.Order("created_at desc").Find(&invites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invites"})
+		return
+	}
+	c.JSON(http.StatusOK, invites)
+}
+
+// DELETE /api/admin/invites/:id
+func RevokeInvite(c *gin.Context) {
+	result := database.DB.Model(&models.UserInvite{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", c.Param("id")).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invite"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found or already used"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Invite revoked"})
+}
+
+func findOpenInvite(tx *gorm.DB, token string) (models.UserInvite, error) {
+	var invite models.UserInvite
+	if err := tx.First(&invite, "token_hash = ?", hashToken(token)).Error; err != nil {
+		return invite, errInviteInvalid
+	}
+	if invite.AcceptedAt != nil || invite.RevokedAt != nil || time.Now().After(invite.ExpiresAt) {
+		return invite, errInviteInvalid
+	}
+	return invite, nil
+}
+
+// GET /api/auth/invites/:token
+// Lets the onboarding page show who the invite is for before a password is set.
+func GetInvite(c *gin.Context) {
+	invite, err := findOpenInvite(database.DB, c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"email":      invite.Email,
+		"role":       invite.Role,
+		"full_name":  invite.FullName,
+		"expires_at": invite.ExpiresAt,
+	})
+}
+
+// POST /api/auth/invites/accept
+func AcceptInvite(c *gin.Context) {
+	var input struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+		FullName string `json:"full_name"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.Password) < 6 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 6 characters"})
+		return
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	var user models.User
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		invite, err := findOpenInvite(tx.Clauses(clause.Locking{Strength: "UPDATE"}), input.Token)
+		if err != nil {
+			return err
+		}
+		var existing int64
+		tx.Model(&models.User{}).Where("email = ?", invite.Email).Count(&existing)
+		if existing > 0 {
+			return errEmailTaken
+		}
+
+		fullName := strings.TrimSpace(input.FullName)
+		if fullName == "" {
+			fullName = invite.FullName
+		}
+		// the invite link went to this address
+		verifiedAt := time.Now()
+		user = models.User{
+			Email:           invite.Email,
+			Password:        string(hashed),
+			FullName:        fullName,
+			Role:            invite.Role,
+			RollNumber:      invite.RollNumber,
+			EmailVerifiedAt: &verifiedAt,
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if invite.GroupID != nil {
+			if err := tx.Model(&models.StudentGroup{ID: *invite.GroupID}).Association("Members").Append(&user); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&invite).Update("accepted_at", time.Now()).Error
+	})
+	switch {
+	case errors.Is(err, errInviteInvalid):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create account"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Account created, you can now log in",
+		"user":    user,
+	})
+}
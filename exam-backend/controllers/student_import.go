@@ -0,0 +1,557 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/csv"
+	"exam-backend/database"
+	"exam-backend/models"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Bulk student import. The whole file is validated before anything is written;
+// if any row is invalid nothing is imported and every row error is reported.
+//
+// Columns (header row required, any order, case-insensitive):
+// Roll Number, Full Name, Email, Group, Password
+
+const (
+	maxImportRows       = 5000
+	importCredentialTTL = time.Hour
+)
+
+// password_mode values
+const (
+	importPasswordColumn   = "column"   // new students need a Password cell
+	importPasswordGenerate = "generate" // random password for new students without one
+	importPasswordInvite   = "invite"   // new students get an invite link to set their own
+)
+
+type importRow struct {
+	Row        int    `json:"row"` // 1-based sheet row
+	RollNumber string `json:"roll_number"`
+	FullName   string `json:"full_name"`
+	Email      string `json:"email"`
+	Group      string `json:"group"`
+	Password   string `json:"-"`
+
+	// filled by prepareImportPasswords before the write transaction
+	hashed    string
+	generated bool
+}
+
+type importRowError struct {
+	Row    int      `json:"row"`
+	Email  string   `json:"email,omitempty"`
+	Errors []string `json:"errors"`
+}
+
+// importCredential is one line of the printable credentials sheet.
+type importCredential struct {
+	RollNumber  string `json:"roll_number"`
+	FullName    string `json:"full_name"`
+	Email       string `json:"email"`
+	Group       string `json:"group"`
+	Password    string `json:"password,omitempty"`
+	InviteToken string `json:"invite_token,omitempty"`
+}
+
+func importCredentialsKey(importID string) string {
+	return "import:credentials:" + importID
+}
+
+// readImportRows parses CSV or XLSX (first sheet) into raw string rows.
+func readImportRows(filename string, r io.Reader) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		return reader.ReadAll()
+	case ".xlsx":
+		xl, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Excel file")
+		}
+		return xl.GetRows(xl.GetSheetName(0))
+	default:
+		return nil, fmt.Errorf("file must be .csv or .xlsx")
+	}
+}
+
+func importHeaderIndex(header []string) (map[string]int, error) {
+	aliases := map[string]string{
+		"roll number":      "roll",
+		"roll no":          "roll",
+		"roll":             "roll",
+		"roll_number":      "roll",
+		"full name":        "name",
+		"full_name":        "name",
+		"name":             "name",
+		"email":            "email",
+		"email address":    "email",
+		"group":            "group",
+		"password":         "password",
+		"initial password": "password",
+	}
+	idx := map[string]int{}
+	for i, h := range header {
+		key := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if col, ok := aliases[key]; ok {
+			idx[col] = i
+		}
+	}
+	for _, required := range []string{"name", "email"} {
+		if _, ok := idx[required]; !ok {
+			return nil, fmt.Errorf("missing column: %s", map[string]string{"name": "Full Name", "email": "Email"}[required])
+		}
+	}
+	return idx, nil
+}
+
+func parseImportRows(rows [][]string) ([]importRow, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+	idx, err := importHeaderIndex(rows[0])
+	if err != nil {
+		return nil, err
+	}
+
+	cell := func(row []string, col string) string {
+		i, ok := idx[col]
+		if !ok {
+			return ""
+		}
+		return strings.TrimSpace(safe(row, i))
+	}
+
+	out := []importRow{}
+	for i, row := range rows[1:] {
+		r := importRow{
+			Row:        i + 2,
+			RollNumber: cell(row, "roll"),
+			FullName:   cell(row, "name"),
+			Email:      normalizeEmail(cell(row, "email")),
+			Group:      cell(row, "group"),
+			Password:   cell(row, "password"),
+		}
+		if r.RollNumber == "" && r.FullName == "" && r.Email == "" && r.Group == "" && r.Password == "" {
+			continue // blank line
+		}
+		out = append(out, r)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("file has no data rows")
+	}
+	if len(out) > maxImportRows {
+		return nil, fmt.Errorf("too many rows (max %d)", maxImportRows)
+	}
+	return out, nil
+}
+
+// validateImportRows checks every row against the file and the database.
+// existing maps lower-cased email -> user for students that will be updated.
+func validateImportRows(rows []importRow, passwordMode string) ([]importRowError, map[string]models.User) {
+	emails := make([]string, 0, len(rows))
+	rolls := []string{}
+	for _, r := range rows {
+		emails = append(emails, strings.ToLower(r.Email))
+		if r.RollNumber != "" {
+			rolls = append(rolls, r.RollNumber)
+		}
+	}
+
+	var users []models.User
+	database.DB.Where("LOWER(email) IN ?", emails).Find(&users)
+	existing := map[string]models.User{}
+	for _, u := range users {
+		existing[strings.ToLower(u.Email)] = u
+	}
+
+	// a second invite-mode import must not hand out another live link for the same address
+	pendingInvite := map[string]bool{}
+	if passwordMode == importPasswordInvite {
+		var invites []models.UserInvite
+		database.DB.Select("email").
+			Where("LOWER(email) IN ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", emails, time.Now()).
+			Find(&invites)
+		for _, inv := range invites {
+			pendingInvite[strings.ToLower(inv.Email)] = true
+		}
+	}
+
+	rollOwner := map[string]string{} // roll -> lower email of current holder
+	if len(rolls) > 0 {
+		var holders []models.User
+		database.DB.Select("id", "email", "roll_number").Where("roll_number IN ?", rolls).Find(&holders)
+		for _, h := range holders {
+			rollOwner[h.RollNumber] = strings.ToLower(h.Email)
+		}
+	}
+
+	seenEmail := map[string]int{}
+	seenRoll := map[string]int{}
+	var rowErrors []importRowError
+
+	for _, r := range rows {
+		var errs []string
+		email := strings.ToLower(r.Email)
+
+		if r.FullName == "" {
+			errs = append(errs, "full name is required")
+		}
+		if r.Email == "" || !strings.Contains(r.Email, "@") {
+			errs = append(errs, "valid email is required")
+		} else if first, dup := seenEmail[email]; dup {
+			errs = append(errs, fmt.Sprintf("duplicate email (also on row %d)", first))
+		} else {
+			seenEmail[email] = r.Row
+		}
+
+		if r.RollNumber != "" {
+			if first, dup := seenRoll[r.RollNumber]; dup {
+				errs = append(errs, fmt.Sprintf("duplicate roll number (also on row %d)", first))
+			} else {
+				seenRoll[r.RollNumber] = r.Row
+			}
+			if owner, ok := rollOwner[r.RollNumber]; ok && owner != email {
+				errs = append(errs, "roll number belongs to another user")
+			}
+		}
+
+		u, isExisting := existing[email]
+		if isExisting && u.Role != "student" {
+			errs = append(errs, "email belongs to a "+u.Role+" account")
+		}
+
+		if r.Password != "" && len(r.Password) < 6 {
+			errs = append(errs, "password must be at least 6 characters")
+		}
+		if !isExisting && passwordMode == importPasswordColumn && r.Password == "" {
+			errs = append(errs, "password is required for new students")
+		}
+		if !isExisting && passwordMode == importPasswordInvite && r.Password == "" && pendingInvite[email] {
+			errs = append(errs, "email already has a pending invite")
+		}
+
+		if len(errs) > 0 {
+			rowErrors = append(rowErrors, importRowError{Row: r.Row, Email: r.Email, Errors: errs})
+		}
+	}
+	return rowErrors, existing
+}
+
+// generatePassword avoids look-alike characters since it ends up on paper.
+func generatePassword() (string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	b := make([]byte, 10)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// prepareImportPasswords generates and hashes passwords up front so the write
+// transaction doesn't hold its locks through thousands of bcrypt rounds.
+// Invite-mode rows without a password are left alone.
+func prepareImportPasswords(rows []importRow, existing map[string]models.User, passwordMode string) error {
+	for i := range rows {
+		r := &rows[i]
+		_, isExisting := existing[strings.ToLower(r.Email)]
+		if r.Password == "" {
+			if isExisting || passwordMode == importPasswordInvite {
+				continue
+			}
+			password, err := generatePassword()
+			if err != nil {
+				return err
+			}
+			r.Password, r.generated = password, true
+		}
+		h, err := bcrypt.GenerateFromPassword([]byte(r.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		r.hashed = string(h)
+	}
+	return nil
+}
+
+// loadOrCreateGroups returns group name -> group, creating missing ones.
+func loadOrCreateGroups(tx *gorm.DB, rows []importRow, adminID uuid.UUID) (map[string]models.StudentGroup, []string, error) {
+	names := map[string]bool{}
+	for _, r := range rows {
+		if r.Group != "" {
+			names[r.Group] = true
+		}
+	}
+	groups := map[string]models.StudentGroup{}
+	created := []string{}
+	for name := range names {
+		var g models.StudentGroup
+		err := tx.Where("name = ?", name).First(&g).Error
+		if err == gorm.ErrRecordNotFound {
+			g = models.StudentGroup{Name: name, CreatedByID: adminID}
+			if err := tx.Create(&g).Error; err != nil {
+				return nil, nil, err
+			}
+			created = append(created, name)
+		} else if err != nil {
+			return nil, nil, err
+		}
+		groups[name] = g
+	}
+	return groups, created, nil
+}
+
+// POST /api/admin/students/import
+// multipart: file (.csv/.xlsx), password_mode (column|generate|invite, default generate),
+// dry_run ("true" = validate only)
+func ImportStudents(c *gin.Context) {
+	passwordMode := c.DefaultPostForm("password_mode", importPasswordGenerate)
+	switch passwordMode {
+	case importPasswordColumn, importPasswordGenerate, importPasswordInvite:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password_mode must be column, generate or invite"})
+		return
+	}
+	dryRun := c.PostForm("dry_run") == "true"
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot open file"})
+		return
+	}
+	defer f.Close()
+
+	raw, err := readImportRows(file.Filename, f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rows, err := parseImportRows(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 1. Validate everything before touching the database
+	rowErrors, existing := validateImportRows(rows, passwordMode)
+	if len(rowErrors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":      "validation_failed",
+			"total_rows": len(rows),
+			"row_errors": rowErrors,
+		})
+		return
+	}
+
+	toCreate, toUpdate := 0, 0
+	for _, r := range rows {
+		if _, ok := existing[strings.ToLower(r.Email)]; ok {
+			toUpdate++
+		} else {
+			toCreate++
+		}
+	}
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run":    true,
+			"total_rows": len(rows),
+			"create":     toCreate,
+			"update":     toUpdate,
+		})
+		return
+	}
+
+	if err := prepareImportPasswords(rows, existing, passwordMode); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare passwords"})
+		return
+	}
+
+	// 2. Write in one transaction
+	adminID, _ := uuid.Parse(c.GetString("userID"))
+	var credentials []importCredential
+	var groupsCreated []string
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		groups, created, err := loadOrCreateGroups(tx, rows, adminID)
+		if err != nil {
+			return err
+		}
+		groupsCreated = created
+
+		for _, r := range rows {
+			user, isExisting := existing[strings.ToLower(r.Email)]
+
+			var groupID *uuid.UUID
+			if g, ok := groups[r.Group]; ok {
+				groupID = &g.ID
+			}
+
+			if !isExisting && passwordMode == importPasswordInvite && r.Password == "" {
+				token, err := randomToken()
+				if err != nil {
+					return err
+				}
+				invite := models.UserInvite{
+					Email:       r.Email,
+					Role:        "student",
+					FullName:    r.FullName,
+					RollNumber:  r.RollNumber,
+					GroupID:     groupID,
+					TokenHash:   hashToken(token),
+					InvitedByID: adminID,
+					ExpiresAt:   time.Now().Add(defaultInviteTTL),
+				}
+				if err := tx.Create(&invite).Error; err != nil {
+					return fmt.Errorf("row %d: %w", r.Row, err)
+				}
+				credentials = append(credentials, importCredential{
+					RollNumber: r.RollNumber, FullName: r.FullName, Email: r.Email, Group: r.Group, InviteToken: token,
+				})
+				continue
+			}
+
+			if isExisting {
+				updates := map[string]interface{}{"full_name": r.FullName}
+				if r.RollNumber != "" {
+					updates["roll_number"] = r.RollNumber
+				}
+				if r.hashed != "" {
+					updates["password"] = r.hashed
+				}
+				if err := tx.Model(&user).Updates(updates).Error; err != nil {
+					return fmt.Errorf("row %d: %w", r.Row, err)
+				}
+			} else {
+				// the address comes from the institution's roster
+				verifiedAt := time.Now()
+				user = models.User{
+					Email:           r.Email,
+					Password:        r.hashed,
+					FullName:        r.FullName,
+					Role:            "student",
+					RollNumber:      r.RollNumber,
+					EmailVerifiedAt: &verifiedAt,
+				}
+				if err := tx.Create(&user).Error; err != nil {
+					return fmt.Errorf("row %d: %w", r.Row, err)
+				}
+			}
+
+			if groupID != nil {
+				if err := tx.Model(&models.StudentGroup{ID: *groupID}).Association("Members").Append(&user); err != nil {
+					return fmt.Errorf("row %d: %w", r.Row, err)
+				}
+			}
+
+			if r.generated || (r.Password != "" && !isExisting) {
+				credentials = append(credentials, importCredential{
+					RollNumber: r.RollNumber, FullName: r.FullName, Email: r.Email, Group: r.Group, Password: r.Password,
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Import failed: " + err.Error()})
+		return
+	}
+
+	resp := gin.H{
+		"total_rows":     len(rows),
+		"created":        toCreate,
+		"updated":        toUpdate,
+		"groups_created": groupsCreated,
+	}
+	// Credentials are kept briefly so the admin can print them, then discarded.
+	if len(credentials) > 0 {
+		importID := uuid.New().String()
+		if err := database.RedisSetJSON(c.Request.Context(), importCredentialsKey(importID), credentials, importCredentialTTL); err == nil {
+			resp["import_id"] = importID
+			resp["credentials_url"] = "/api/admin/students/import/" + importID + "/credentials"
+			resp["credentials_expire_at"] = time.Now().Add(importCredentialTTL)
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GET /api/admin/students/import/:id/credentials
+// Printable XLSX with the generated passwords / invite tokens of one import.
+func DownloadImportCredentials(c *gin.Context) {
+	var credentials []importCredential
+	if err := database.RedisGetJSON(c.Request.Context(), importCredentialsKey(c.Param("id")), &credentials); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credentials expired or not found"})
+		return
+	}
+
+	f := excelize.NewFile()
+	sheet := "Sheet1"
+
+	headers := []string{"Roll Number", "Full Name", "Email", "Group", "Password", "Invite Token"}
+	style, _ := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true, Color: "#FFFFFF"},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"#4F81BD"}, Pattern: 1},
+	})
+	for i, h := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(sheet, cell, h)
+		f.SetCellStyle(sheet, cell, cell, style)
+	}
+	for r, cr := range credentials {
+		for col, val := range []string{cr.RollNumber, cr.FullName, cr.Email, cr.Group, cr.Password, cr.InviteToken} {
+			cell, _ := excelize.CoordinatesToCellName(col+1, r+2)
+			f.SetCellValue(sheet, cell, val)
+		}
+	}
+
+	f.SetColWidth(sheet, "A", "A", 14) // Roll Number
+	f.SetColWidth(sheet, "B", "B", 28) // Full Name
+	f.SetColWidth(sheet, "C", "C", 32) // Email
+	f.SetColWidth(sheet, "D", "D", 16) // Group
+	f.SetColWidth(sheet, "E", "E", 14) // Password
+	f.SetColWidth(sheet, "F", "F", 48) // Invite Token
+
+	// fit all columns on one landscape page width when printed
+	landscape := "landscape"
+	fitToWidth, fitToHeight := 1, 0
+	f.SetPageLayout(sheet, &excelize.PageLayoutOptions{
+		Orientation: &landscape,
+		FitToWidth:  &fitToWidth,
+		FitToHeight: &fitToHeight,
+	})
+	fit := true
+	f.SetSheetProps(sheet, &excelize.SheetPropsOptions{FitToPage: &fit})
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate sheet"})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=student_credentials.xlsx")
+	c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", buf.Bytes())
+}
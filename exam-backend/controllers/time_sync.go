@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"exam-backend/database"
+	"exam-backend/middleware"
+	"exam-backend/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// The server's computeTimeLeftSeconds is what SubmitAttempt enforces, so candidate
+// clocks are corrected from it: pushed over /ws/exam every timeSyncInterval and
+// available on demand from GET /api/attempts/:id/time.
+
+const timeSyncInterval = 30 * time.Second
+
+// attemptClock reloads only what the countdown depends on (pause state changes
+// while the socket is open); exam and slot windows don't.
+func attemptClock(attempt models.ExamAttempt) (models.ExamAttempt, error) {
+	var fresh models.ExamAttempt
+	if err := database.DB.
+		Select("id", "started_at", "submitted_at", "is_terminated", "paused_at", "paused_seconds").
+		First(&fresh, "id = ?", attempt.ID).Error; err != nil {
+		return attempt, err
+	}
+	attempt.StartedAt = fresh.StartedAt
+	attempt.SubmittedAt = fresh.SubmittedAt
+	attempt.IsTerminated = fresh.IsTerminated
+	attempt.PausedAt = fresh.PausedAt
+	attempt.PausedSeconds = fresh.PausedSeconds
+	return attempt, nil
+}
+
+// runTimeSync pushes time_sync frames until done is closed or the attempt ends.
+// attempt must have Exam (and Slot, if any) loaded.
+func runTimeSync(ec *examConn, attempt models.ExamAttempt, done <-chan struct{}) {
+	ticker := time.NewTicker(timeSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			current, err := attemptClock(attempt)
+			if err != nil {
+				continue
+			}
+			if current.SubmittedAt != nil || current.IsTerminated {
+				return
+			}
+			msg := timeSyncMessage(computeTimeLeftSeconds(current.Exam, current))
+			msg["paused"] = current.PausedAt != nil
+			if err := ec.send(msg); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// GET /api/time
+// Unauthenticated and DB-free so clients can measure their offset cheaply.
+func GetServerTime(c *gin.Context) {
+	now := time.Now().UTC()
+	c.JSON(http.StatusOK, gin.H{
+		"server_time": now.UnixMilli(),
+		"iso":         now.Format(time.RFC3339Nano),
+	})
+}
+
+// GET /api/attempts/:id/time
+func GetAttemptTime(c *gin.Context) {
+	var attempt models.ExamAttempt
+	if err := database.DB.
+		Select("id", "exam_id", "student_id", "slot_id", "started_at", "submitted_at", "is_terminated", "paused_at", "paused_seconds").
+		Preload("Exam", func(tx *gorm.DB) *gorm.DB { return tx.Select("id", "duration_minutes", "end_time") }).
+		Preload("Slot").
+		First(&attempt, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attempt not found"})
+		return
+	}
+
+	if c.GetString("userID") != attempt.StudentID.String() && !middleware.HasPermission(c, models.PermProctorMonitor) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	status := "in_progress"
+	var timeLeft int64
+	switch {
+	case attempt.IsTerminated:
+		status = "terminated"
+	case attempt.SubmittedAt != nil:
+		status = "submitted"
+	default:
+		timeLeft = computeTimeLeftSeconds(attempt.Exam, attempt)
+		if attempt.PausedAt != nil {
+			status = "paused"
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"attempt_id":  attempt.ID,
+		"status":      status,
+		"time_left":   timeLeft,
+		"server_time": time.Now().UnixMilli(),
+	})
+}
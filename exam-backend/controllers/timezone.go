@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"exam-backend/models"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// All timestamps are stored in UTC (see database.Connect). Exams carry their own
+// IANA time zone which is used when parsing local schedule input and when
+// rendering times back to clients, so every response has an explicit offset.
+
+const fallbackTimeZone = "Asia/Kolkata"
+
+var (
+	defaultLocOnce sync.Once
+	defaultLoc     *time.Location
+)
+
+// defaultLocation reads DEFAULT_TIMEZONE lazily (after godotenv has run in main).
+func defaultLocation() *time.Location {
+	defaultLocOnce.Do(func() {
+		name := os.Getenv("DEFAULT_TIMEZONE")
+		if name == "" {
+			name = fallbackTimeZone
+		}
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			log.Printf("Invalid DEFAULT_TIMEZONE %q, using UTC: %v", name, err)
+			loc = time.UTC
+		}
+		defaultLoc = loc
+	})
+	return defaultLoc
+}
+
+// DefaultTimeZoneName is used for exams created without an explicit time zone.
+func DefaultTimeZoneName() string {
+	return defaultLocation().String()
+}
+
+var (
+	locCacheMu sync.RWMutex
+	locCache   = map[string]*time.Location{}
+)
+
+// loadLocation caches time.LoadLocation, which reads the tzdata from disk.
+func loadLocation(name string) (*time.Location, error) {
+	locCacheMu.RLock()
+	loc, ok := locCache[name]
+	locCacheMu.RUnlock()
+	if ok {
+		return loc, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locCacheMu.Lock()
+	locCache[name] = loc
+	locCacheMu.Unlock()
+	return loc, nil
+}
+
+// examLocation returns the exam's time zone, falling back to the configured default.
+func examLocation(exam models.Exam) *time.Location {
+	if exam.TimeZone == "" {
+		return defaultLocation()
+	}
+	loc, err := loadLocation(exam.TimeZone)
+	if err != nil {
+		return defaultLocation()
+	}
+	return loc
+}
+
+// localizeExam converts the exam's schedule into its own time zone for responses.
+func localizeExam(exam *models.Exam) {
+	loc := examLocation(*exam)
+	if !exam.StartTime.IsZero() {
+		exam.StartTime = exam.StartTime.In(loc)
+	}
+	if !exam.EndTime.IsZero() {
+		exam.EndTime = exam.EndTime.In(loc)
+	}
+	exam.CreatedAt = exam.CreatedAt.In(loc)
+	for i := range exam.Slots {
+		exam.Slots[i].StartTime = exam.Slots[i].StartTime.In(loc)
+		exam.Slots[i].EndTime = exam.Slots[i].EndTime.In(loc)
+	}
+	if exam.TimeZone == "" {
+		exam.TimeZone = loc.String()
+	}
+}
+
+// parseLocalTime parses "2006-01-02T15:04[:05]" wall-clock input in loc.
+func parseLocalTime(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid local time '%s' (expected YYYY-MM-DDTHH:MM)", value)
+}
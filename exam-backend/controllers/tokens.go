@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"exam-backend/database"
+	"exam-backend/middleware"
+	"exam-backend/models"
+	"exam-backend/signing"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// A login creates a UserSession. The client holds a short-lived access token (JWT
+// whose jti is the session's Jti, so AuthMiddleware's session check still applies)
+// and an opaque refresh token that is rotated on every POST /api/auth/refresh.
+
+// refreshReuseGrace tolerates two tabs refreshing with the same token at once.
+const refreshReuseGrace = 10 * time.Second
+
+var (
+	errRefreshInvalid  = errors.New("invalid_refresh_token")
+	errRefreshReused   = errors.New("refresh_token_reused")
+	errRefreshExpired  = errors.New("refresh_token_expired")
+	errRefreshInFlight = errors.New("refresh_in_progress")
+	errSessionInactive = errors.New("session_not_active")
+)
+
+func durationFromEnv(name string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(name)); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
+
+// ACCESS_TOKEN_TTL (default 15m) and REFRESH_TOKEN_TTL (default 72h), Go duration syntax.
+func accessTokenTTL() time.Duration  { return durationFromEnv("ACCESS_TOKEN_TTL", 15*time.Minute) }
+func refreshTokenTTL() time.Duration { return durationFromEnv("REFRESH_TOKEN_TTL", 72*time.Hour) }
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func signAccessToken(user models.User, jti string, mfa bool) (string, error) {
+	now := time.Now()
+	claims := &models.Claims{
+		UserID: user.ID.String(),
+		Role:   user.Role,
+		MFA:    mfa,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    signing.Issuer(),
+			Subject:   user.ID.String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL())),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        jti,
+		},
+	}
+	return signing.Current.Sign(claims)
+}
+
+func createRefreshToken(tx *gorm.DB, sessionID uuid.UUID) (string, models.RefreshToken, error) {
+	raw, err := randomToken()
+	if err != nil {
+		return "", models.RefreshToken{}, err
+	}
+	rec := models.RefreshToken{
+		SessionID: sessionID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(refreshTokenTTL()),
+	}
+	if err := tx.Create(&rec).Error; err != nil {
+		return "", rec, err
+	}
+	return raw, rec, nil
+}
+
+func tokenResponse(accessToken, refreshToken string) gin.H {
+	return gin.H{
+		"token":         accessToken,
+		"refresh_token": refreshToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(accessTokenTTL().Seconds()),
+	}
+}
+
+// startSession creates the UserSession and the first token pair for a login.
+// mfa records that the login passed a second factor.
+func startSession(c *gin.Context, user models.User, fingerprint string, mfa bool) (gin.H, error) {
+	jti := uuid.New().String()
+	exp := time.Now().Add(refreshTokenTTL())
+	sess := models.UserSession{
+		ID:                uuid.New(),
+		UserID:            user.ID,
+		Jti:               jti,
+		DeviceFingerprint: fingerprint, // Save fingerprint for the next check
+		IP:                c.ClientIP(),
+		Active:            true,
+		MFAVerified:       mfa,
+		CreatedAt:         time.Now(),
+		ExpiresAt:         &exp,
+	}
+
+	var refresh string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&sess).Error; err != nil {
+			return err
+		}
+		var err error
+		refresh, _, err = createRefreshToken(tx, sess.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := signAccessToken(user, jti, mfa)
+	if err != nil {
+		return nil, err
+	}
+	return tokenResponse(access, refresh), nil
+}
+
+// revokeSessionFamily ends the session and every refresh token issued for it.
+func revokeSessionFamily(tx *gorm.DB, sessionID uuid.UUID) error {
+	_, err := revokeSessions(tx, "id = ?", sessionID)
+	return err
+}
+
+// revokeSessions deactivates the matching sessions and their refresh tokens and
+// tells AuthMiddleware right away. Returns how many active sessions were ended.
+func revokeSessions(tx *gorm.DB, query interface{}, args ...interface{}) (int, error) {
+	var sessions []models.UserSession
+	if err := tx.Select("id", "jti", "active").Where(query, args...).Find(&sessions).Error; err != nil {
+		return 0, err
+	}
+	if len(sessions) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(sessions))
+	jtis := make([]string, 0, len(sessions))
+	ended := 0
+	for _, s := range sessions {
+		ids = append(ids, s.ID)
+		jtis = append(jtis, s.Jti)
+		if s.Active {
+			ended++
+		}
+	}
+
+	if err := tx.Model(&models.RefreshToken{}).
+		Where("session_id IN ? AND revoked_at IS NULL", ids).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Model(&models.UserSession{}).Where("id IN ?", ids).Update("active", false).Error; err != nil {
+		return 0, err
+	}
+	middleware.MarkSessionsRevoked(context.Background(), jtis...)
+	return ended, nil
+}
+
+// rotateRefreshToken consumes raw and returns the session's user, the session and the next refresh token.
+func rotateRefreshToken(raw string) (models.User, models.UserSession, string, error) {
+	var user models.User
+	var session models.UserSession
+	var next string
+	reused := false
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&current, "token_hash = ?", hashToken(raw)).Error; err != nil {
+			return errRefreshInvalid
+		}
+
+		now := time.Now()
+		if current.UsedAt != nil || current.RevokedAt != nil {
+			if current.RevokedAt == nil && now.Sub(*current.UsedAt) < refreshReuseGrace {
+				return errRefreshInFlight
+			}
+			// commit the revocation; the error is reported after the transaction
+			reused = true
+			return revokeSessionFamily(tx, current.SessionID)
+		}
+		if now.After(current.ExpiresAt) {
+			return errRefreshExpired
+		}
+
+		var sess models.UserSession
+		if err := tx.First(&sess, "id = ?", current.SessionID).Error; err != nil || !sess.Active {
+			return errSessionInactive
+		}
+		if err := tx.First(&user, "id = ?", sess.UserID).Error; err != nil {
+			return errSessionInactive
+		}
+
+		raw, rec, err := createRefreshToken(tx, sess.ID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&current).Updates(map[string]interface{}{
+			"used_at":        now,
+			"replaced_by_id": rec.ID,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&sess).Update("expires_at", rec.ExpiresAt).Error; err != nil {
+			return err
+		}
+
+		session, next = sess, raw
+		return nil
+	})
+	if err == nil && reused {
+		err = errRefreshReused
+	}
+	return user, session, next, err
+}
+
+// GET /.well-known/jwks.json
+// Public keys for verifying access tokens outside this service.
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, signing.Current.JWKS())
+}
+
+// POST /api/auth/refresh
+func RefreshSession(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+		return
+	}
+
+	user, sess, refresh, err := rotateRefreshToken(input.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, errRefreshInFlight):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, errRefreshInvalid), errors.Is(err, errRefreshReused),
+			errors.Is(err, errRefreshExpired), errors.Is(err, errSessionInactive):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		}
+		return
+	}
+
+	access, err := signAccessToken(user, sess.Jti, sess.MFAVerified)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	c.JSON(http.StatusOK, tokenResponse(access, refresh))
+}
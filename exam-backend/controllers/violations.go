@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"exam-backend/database"
+	"exam-backend/models"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recordViolation bumps the attempt's counter for the event and returns the new count.
+// tab-switch keeps using the tab_switches column so existing reports stay correct.
+func recordViolation(attempt *models.ExamAttempt, event string) (int, error) {
+	if event == models.ViolationTabSwitch {
+		if err := database.DB.Model(&models.ExamAttempt{}).
+			Where("id = ? AND submitted_at IS NULL", attempt.ID).
+			UpdateColumn("tab_switches", gorm.Expr("tab_switches + ?", 1)).Error; err != nil {
+			return 0, err
+		}
+		var fresh models.ExamAttempt
+		if err := database.DB.Select("id", "tab_switches").First(&fresh, "id = ?", attempt.ID).Error; err != nil {
+			return 0, err
+		}
+		attempt.TabSwitches = fresh.TabSwitches
+	}
+
+	// one socket per attempt, so a read-modify-write of the counters map is safe here
+	if attempt.ViolationCounts == nil {
+		attempt.ViolationCounts = map[string]int{}
+	}
+	if event == models.ViolationTabSwitch {
+		attempt.ViolationCounts[event] = attempt.TabSwitches
+	} else {
+		attempt.ViolationCounts[event]++
+	}
+	if err := database.DB.Model(&models.ExamAttempt{}).
+		Where("id = ? AND submitted_at IS NULL", attempt.ID).
+		UpdateColumn("violation_counts", toJSONString(attempt.ViolationCounts)).Error; err != nil {
+		return 0, err
+	}
+
+	return attempt.ViolationCounts[event], nil
+}
+
+// violationTerminationReason returns the stored termination_reason and the v1
+// text frame for a violation. tab-switch keeps the strings of the original
+// hard-coded limit so existing reports and clients see no change.
+func violationTerminationReason(event string) (reason, legacy string) {
+	if event == models.ViolationTabSwitch {
+		return "tab_switches_exceeded", "terminated:tab_switches"
+	}
+	name := strings.ReplaceAll(event, "-", "_")
+	return name + "_exceeded", "terminated:" + name
+}
+
+// applyViolationPolicy runs the exam's rules for one violation.
+// Returns true when the attempt was terminated (the caller must close the socket).
+func applyViolationPolicy(ctx context.Context, ec *examConn, exam models.Exam, attempt *models.ExamAttempt, event string) bool {
+	count, err := recordViolation(attempt, event)
+	if err != nil {
+		return false
+	}
+
+	attemptID := attempt.ID.String()
+	publishProctorEvent(ctx, attempt.ExamID.String(), attemptID, "violation", map[string]interface{}{
+		"event":        event,
+		"count":        count,
+		"tab_switches": attempt.TabSwitches,
+	})
+
+	rules := exam.EffectiveViolationPolicy().RulesFor(event)
+
+	// terminate wins over everything else
+	terminateAt := 0
+	for _, r := range rules {
+		if r.Action != models.ViolationActionTerminate {
+			continue
+		}
+		if terminateAt == 0 || r.Threshold < terminateAt {
+			terminateAt = r.Threshold
+		}
+		if count >= r.Threshold {
+			reason, legacy := violationTerminationReason(event)
+			terminateAttempt(attemptID, reason)
+			if r.Message != "" {
+				_ = ec.send(gin.H{"type": "terminated", "reason": reason, "message": r.Message})
+			} else if ec.protocol >= wsProtocolV2 {
+				_ = ec.send(gin.H{"type": "terminated", "reason": reason})
+			}
+			// legacy text frame understood by the current client
+			_ = ec.sendLegacyText(legacy)
+			return true
+		}
+	}
+
+	for _, r := range rules {
+		switch r.Action {
+		case models.ViolationActionFlag:
+			if count == r.Threshold {
+				flagAttemptForReview(ctx, attempt, fmt.Sprintf("%s x%d", event, count))
+			}
+		case models.ViolationActionWarn:
+			if count >= r.Threshold {
+				msg := gin.H{
+					"type":    "warning",
+					"event":   event,
+					"count":   count,
+					"message": r.Message,
+				}
+				if terminateAt > 0 {
+					msg["remaining"] = terminateAt - count
+				}
+				_ = ec.send(msg)
+			}
+		}
+	}
+	return false
+}
+
+func flagAttemptForReview(ctx context.Context, attempt *models.ExamAttempt, reason string) {
+	attempt.FlaggedForReview = true
+	attempt.FlagReasons = append(attempt.FlagReasons, reason)
+	_ = database.DB.Model(&models.ExamAttempt{}).
+		Where("id = ?", attempt.ID).
+		UpdateColumns(map[string]interface{}{
+			"flagged_for_review": true,
+			"flag_reasons":       toJSONString(attempt.FlagReasons),
+		}).Error
+
+	publishProctorEvent(ctx, attempt.ExamID.String(), attempt.ID.String(), "flagged", map[string]interface{}{
+		"reason": reason,
+	})
+}
+
+// toJSONString encodes values for serializer:json columns in map-based updates,
+// which bypass GORM's serializer.
+func toJSONString(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "null"
+	}
+	return string(data)
+}
+
+// GET /api/admin/exams/:id/violation-policy
+func GetViolationPolicy(c *gin.Context) {
+	var exam models.Exam
+	if err := database.DB.Select("id", "violation_policy").First(&exam, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"exam_id":    exam.ID,
+		"is_default": exam.ViolationPolicy == nil,
+		"policy":     exam.EffectiveViolationPolicy(),
+	})
+}
+
+// PUT /api/admin/exams/:id/violation-policy
+func UpdateViolationPolicy(c *gin.Context) {
+	var policy models.ViolationPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := policy.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var exam models.Exam
+	if err := database.DB.Select("id").First(&exam, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+
+	exam.ViolationPolicy = &policy
+	if err := database.DB.Model(&exam).Select("violation_policy").Updates(&exam).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Violation policy updated", "policy": policy})
+}
+
+// DELETE /api/admin/exams/:id/violation-policy (back to the default policy)
+func ResetViolationPolicy(c *gin.Context) {
+	result := database.DB.Model(&models.Exam{}).Where("id = ?", c.Param("id")).Update("violation_policy", gorm.Expr("NULL"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset policy"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Violation policy reset to default", "policy": models.DefaultViolationPolicy()})
+}
@@ -2,7 +2,6 @@ package controllers
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
 	"time"
 
@@ -13,7 +12,6 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
-	"gorm.io/gorm"
 )
 
 var upgrader = websocket.Upgrader{
@@ -33,13 +31,6 @@ func redisDel(key string) error {
 	return database.RedisDel(context.Background(), key)
 }
 
-// ExamWebSocket handles a websocket for an exam attempt.
-// query params: attempt_id, token, fingerprint (optional)
-// ExamWebSocket handles a websocket for an exam attempt.
-// backend/controllers/websocket_exam.go
-
-// backend/controllers/websocket_exam.go
-
 func handleDisconnectWithGracePeriod(attemptID string, waitTime time.Duration) {
 	// 1. Wait for grace period
 	time.Sleep(waitTime)
@@ -61,6 +52,10 @@ func handleDisconnectWithGracePeriod(attemptID string, waitTime time.Duration) {
 		return // Already done
 	}
 
+	if attempt.PausedAt != nil {
+		return // Proctor paused the clock; ResumeAttempt restarts the timer
+	}
+
 	// 4. AUTO-SUBMIT & SCORE
 	// We calculate the score now so the record is complete.
 	score, total := evaluateScore(attempt.Exam, attempt.Answers)
@@ -74,12 +69,20 @@ func handleDisconnectWithGracePeriod(attemptID string, waitTime time.Duration) {
 		"passed":       score >= attempt.Exam.PassingScore,
 		// We leave termination_reason empty because this is a valid auto-submit
 	})
+
+	publishProctorEvent(context.Background(), attempt.ExamID.String(), attemptID, "submitted", map[string]interface{}{
+		"score": score,
+		"auto":  true,
+	})
 }
 
+// ExamWebSocket handles a websocket for an exam attempt.
+// query params: attempt_id, token, fingerprint (optional), protocol (optional, "2" for envelopes)
 func ExamWebSocket(c *gin.Context) {
 	attemptID := c.Query("attempt_id")
 	token := c.Query("token")
 	fingerprint := c.Query("fingerprint")
+	protocol := parseProtocolVersion(c.Query("protocol"))
 
 	if attemptID == "" || token == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "missing_params"})
@@ -94,7 +97,7 @@ func ExamWebSocket(c *gin.Context) {
 	}
 
 	var attempt models.ExamAttempt
-	if err := database.DB.First(&attempt, "id = ?", aid).Error; err != nil {
+	if err := database.DB.Preload("Exam").Preload("Slot").First(&attempt, "id = ?", aid).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "attempt_not_found"})
 		return
 	}
@@ -105,26 +108,65 @@ func ExamWebSocket(c *gin.Context) {
 		return
 	}
 
-	// enforce single active websocket session
-	wsKey := "ws_active:" + attemptID
+	// same network restriction as StartAttempt
+	if !clientIPAllowed(attempt.Exam, c.ClientIP()) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "ip_not_allowed"})
+		return
+	}
 
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+
+	// enforce single active websocket session: the newest connection wins and
+	// the older one (on this or any other replica) is kicked
+	wsKey := "ws_active:" + attemptID
 	ttl := time.Duration(attempt.Exam.DurationMinutes+10) * time.Minute
 
+	ec := newExamConn(conn, protocol)
+
 	// Set the key to mark user as ONLINE
-	_ = redisSet(wsKey, token, ttl)
+	_ = redisSet(wsKey, ec.id, ttl)
+	registerExamConn(attemptID, ec)
+
+	examID := attempt.ExamID.String()
+	ctx := context.Background()
+	rememberAttemptExam(ctx, attemptID, examID)
+	publishProctorEvent(ctx, examID, attemptID, "connected", map[string]interface{}{
+		"ip": c.ClientIP(),
+	})
 
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
-	if err != nil {
-		redisDel(wsKey)
-		return
+	src := eventSource{
+		AttemptID:   attempt.ID,
+		ExamID:      attempt.ExamID,
+		IP:          c.ClientIP(),
+		Fingerprint: fingerprint,
 	}
+	recordAttemptEvent(src, "connected", map[string]interface{}{
+		"user_agent": c.Request.UserAgent(),
+	})
+	closeReason := "read_error"
 
 	// CLEANUP: When connection closes (Internet disconnects)
 	defer func() {
+		unregisterExamConn(attemptID, ec)
 		conn.Close()
 
-		// 1. Mark user as OFFLINE immediately in Redis
-		redisDel(wsKey)
+		if r := ec.closeReason(); r != "" {
+			closeReason = r
+		}
+		recordAttemptEvent(src, "disconnected", map[string]interface{}{
+			"reason": closeReason,
+		})
+		if closeReason == "replaced_by_new_connection" {
+			// the new connection owns ws_active and the reaper
+			return
+		}
+
+		// 1. Mark user as OFFLINE immediately in Redis (unless a newer socket took over)
+		_ = database.RedisDelIfEquals(ctx, wsKey, ec.id)
+		publishProctorEvent(ctx, examID, attemptID, "disconnected", nil)
 
 		// 2. Spawn the "Grim Reaper" (Background Timer)
 		// This runs independently even after the request finishes
@@ -139,7 +181,29 @@ func ExamWebSocket(c *gin.Context) {
 		_ = database.DB.Model(&attempt).Update("device_fingerprint", fingerprint).Error
 	}
 
-	conn.SetReadLimit(512)
+	if protocol >= wsProtocolV2 {
+		_ = ec.send(helloMessage(heartbeatInterval, storedAnswerSeq(ctx, attemptID)))
+	}
+	_ = ec.send(timeSyncMessage(computeTimeLeftSeconds(attempt.Exam, attempt)))
+
+	// keep the candidate's countdown on the server clock
+	timeSyncDone := make(chan struct{})
+	defer close(timeSyncDone)
+	go runTimeSync(ec, attempt, timeSyncDone)
+
+	// tell a reconnecting client it is still paused
+	if attempt.PausedAt != nil {
+		_ = ec.send(gin.H{"type": "paused", "reason": attempt.PauseReason})
+	}
+
+	// replay announcements the candidate has not acknowledged yet
+	if pending, err := pendingAnnouncements(attempt.ExamID, attempt.ID); err == nil {
+		for _, a := range pending {
+			_ = ec.send(announcementMessage(a))
+		}
+	}
+
+	conn.SetReadLimit(wsMaxMessageBytes)
 	// Initial deadline
 	conn.SetReadDeadline(time.Now().Add(heartbeatTimeout))
 
@@ -151,6 +215,15 @@ func ExamWebSocket(c *gin.Context) {
 		return nil
 	})
 
+	beat := func() {
+		// CRITICAL FIX: Extend the deadline!
+		conn.SetReadDeadline(time.Now().Add(heartbeatTimeout))
+		lastBeat = time.Now()
+
+		_ = redisSet(lastBeatKey(attemptID), lastBeat.UTC().Format(time.RFC3339), ttl)
+		publishProctorEvent(ctx, examID, attemptID, "heartbeat", nil)
+	}
+
 	for {
 		mt, message, err := conn.ReadMessage()
 		if err != nil {
@@ -163,37 +236,69 @@ func ExamWebSocket(c *gin.Context) {
 			msg := string(message)
 
 			// FIX IS HERE: Handle text-based pings from React
+			// (accepted on every protocol version while clients roll over to envelopes)
 			if msg == "ping" || msg == "heartbeat" {
-				_ = conn.WriteMessage(websocket.TextMessage, []byte("pong"))
+				if ec.protocol >= wsProtocolV2 {
+					_ = ec.send(gin.H{"type": "pong", "server_time": time.Now().UnixMilli()})
+				} else {
+					_ = ec.writeText("pong")
+				}
+				beat()
+				continue
+			}
 
-				// CRITICAL FIX: Extend the deadline!
-				conn.SetReadDeadline(time.Now().Add(heartbeatTimeout))
-				lastBeat = time.Now()
+			// Handle JSON commands
+			cmd, msgID, err := decodeClientMessage(ec.protocol, message)
+			if err != nil {
+				recordAttemptEvent(src, "invalid_message", map[string]interface{}{
+					"raw": msg,
+				})
+				ec.sendError(msgID, "invalid_message", err.Error())
+				continue
+			}
 
+			cmdType, _ := cmd["type"].(string)
+			if cmdType == "ping" || cmdType == "heartbeat" {
+				_ = ec.send(gin.H{"type": "pong", "server_time": time.Now().UnixMilli()})
+				beat()
 				continue
 			}
 
-			// Handle JSON commands
-			var cmd map[string]interface{}
-			if err := json.Unmarshal(message, &cmd); err == nil {
-				if cmdType, ok := cmd["type"].(string); ok {
-					switch cmdType {
-					case "tab-switch":
-						_ = database.DB.Model(&attempt).Where("id = ? AND submitted_at IS NULL", attempt.ID).UpdateColumn("tab_switches", gorm.Expr("tab_switches + ?", 1)).Error
-						_ = database.DB.First(&attempt, "id = ?", attempt.ID).Error
-						if attempt.TabSwitches > 3 {
-							terminateAttempt(aid.String(), "tab_switches_exceeded")
-							_ = conn.WriteMessage(websocket.TextMessage, []byte("terminated:tab_switches"))
-							return
-						}
-					}
+			// autosave is not a proctoring signal; keep it out of the event store
+			if cmdType == "answer" {
+				handleAnswerMessage(ctx, ec, examID, attemptID, msgID, cmd)
+				continue
+			}
+
+			recordAttemptEvent(src, cmdType, cmd)
+			switch {
+			case models.IsViolationEvent(cmdType):
+				if applyViolationPolicy(ctx, ec, attempt.Exam, &attempt, cmdType) {
+					closeReason = "terminated"
+					return
 				}
+			case cmdType == "announcement-ack":
+				annID, _ := cmd["announcement_id"].(string)
+				if annID == "" {
+					annID, _ = cmd["id"].(string) // v1 sends the announcement id as "id"
+				}
+				ackAnnouncement(ctx, attempt.ExamID, attempt.ID, annID)
+			case cmdType == "time_sync":
+				_ = ec.send(timeSyncMessage(computeTimeLeftSeconds(attempt.Exam, attempt)))
+			case cmdType == "close":
+				// client is leaving on purpose; the socket close follows
+				closeReason = "client_close"
+			default:
+				ec.sendError(msgID, "unknown_type", "unknown message type: "+cmdType)
+				continue
 			}
+			ec.ack(msgID, nil)
 		}
 
 		// Fallback check
 		if time.Since(lastBeat) > heartbeatTimeout {
 			// CONNECTION TIMEOUT: Break loop, let defer handle it
+			closeReason = "heartbeat_timeout"
 			return
 		}
 	}
@@ -216,6 +321,12 @@ func terminateAttempt(attemptID string, reason string) {
 	// Only delete Redis key if we actually terminated (or just always delete it to be safe)
 	_ = redisDel("ws_active:" + attemptID)
 
+	if result.RowsAffected > 0 {
+		publishProctorEvent(context.Background(), "", attemptID, "terminated", map[string]interface{}{
+			"reason": reason,
+		})
+	}
+
 	// Optional: Log if we prevented a false termination
 	if result.RowsAffected == 0 {
 		// fmt.Printf("Prevented false termination for attempt %s (reason: %s)\n", attemptID, reason)
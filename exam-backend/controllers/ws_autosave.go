@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"exam-backend/database"
+	"strconv"
+	"time"
+)
+
+// Autosave over /ws/exam. The client sends only the answers that changed:
+//
+//	{"type": "answer", "seq": 17, "answers": {"<question_id>": "B"}}
+//	{"type": "answer", "seq": 18, "question_id": "<question_id>", "answer": "C"}
+//
+// and the server replies {"type": "answer_ack", "seq": 18} once the delta is in
+// attempt:answers:<id> (the key UpdateProgress and SubmitAttempt use). seq must
+// increase per attempt. A seq at or below the persisted one is not applied and
+// gets answer_error "stale_seq" carrying stored_seq: a retry whose seq is covered
+// can be treated as saved, a client that lost its counter (e.g. a page reload)
+// continues from stored_seq+1 and resends. The v2 hello frame also carries
+// answer_seq so a reconnecting client can resume without the round trip.
+
+var (
+	errAnswerSeq     = errors.New("seq must be a positive integer")
+	errAnswerPayload = errors.New("answers must map question ids to strings")
+	errAnswerPaused  = errors.New("attempt_paused")
+	errAnswerStale   = errors.New("seq is not newer than the last saved one")
+)
+
+func answerSeqKey(attemptID string) string {
+	return "attempt:answer_seq:" + attemptID
+}
+
+// storedAnswerSeq is the last autosave seq persisted for the attempt, 0 if none.
+func storedAnswerSeq(ctx context.Context, attemptID string) int64 {
+	raw, err := database.RedisGet(ctx, answerSeqKey(attemptID))
+	if err != nil {
+		return 0
+	}
+	seq, _ := strconv.ParseInt(raw, 10, 64)
+	return seq
+}
+
+func parseAnswerDelta(cmd map[string]interface{}) (int64, map[string]string, error) {
+	rawSeq, ok := cmd["seq"].(float64)
+	if !ok || rawSeq < 1 || rawSeq != float64(int64(rawSeq)) {
+		return 0, nil, errAnswerSeq
+	}
+
+	fields := map[string]string{}
+	if raw, ok := cmd["answers"].(map[string]interface{}); ok {
+		for qid, v := range raw {
+			s, ok := v.(string)
+			if !ok || qid == "" {
+				return 0, nil, errAnswerPayload
+			}
+			fields[qid] = s
+		}
+	}
+	if qid, ok := cmd["question_id"].(string); ok && qid != "" {
+		s, ok := cmd["answer"].(string)
+		if !ok {
+			return 0, nil, errAnswerPayload
+		}
+		fields[qid] = s
+	}
+	if len(fields) == 0 {
+		return 0, nil, errAnswerPayload
+	}
+	return int64(rawSeq), fields, nil
+}
+
+// saveAnswerDelta returns the highest seq persisted for the attempt.
+func saveAnswerDelta(ctx context.Context, examID, attemptID string, cmd map[string]interface{}) (int64, error) {
+	seq, fields, err := parseAnswerDelta(cmd)
+	if err != nil {
+		return 0, err
+	}
+	if isAttemptPaused(ctx, attemptID) {
+		return 0, errAnswerPaused
+	}
+
+	stored, answered, applied, err := database.RedisMergeJSONMap(ctx, "attempt:answers:"+attemptID, answerSeqKey(attemptID), seq, fields, 3*time.Hour)
+	if err != nil {
+		return 0, err
+	}
+	if !applied {
+		return stored, errAnswerStale
+	}
+	_ = database.RedisSet(ctx, "attempt:dirty:"+attemptID, "1", 3*time.Hour)
+	publishProctorEvent(ctx, examID, attemptID, "autosave", map[string]interface{}{
+		"answered": answered,
+	})
+	return stored, nil
+}
+
+// handleAnswerMessage writes the delta and tells the client what was persisted.
+// Both protocol versions get answer_ack / answer_error.
+func handleAnswerMessage(ctx context.Context, ec *examConn, examID, attemptID, msgID string, cmd map[string]interface{}) {
+	stored, err := saveAnswerDelta(ctx, examID, attemptID, cmd)
+	if err != nil {
+		code := "autosave_failed"
+		switch {
+		case errors.Is(err, errAnswerSeq), errors.Is(err, errAnswerPayload):
+			code = "invalid_answer"
+		case errors.Is(err, errAnswerPaused):
+			code = "attempt_paused"
+		case errors.Is(err, errAnswerStale):
+			code = "stale_seq"
+		}
+		reply := map[string]interface{}{
+			"type":    "answer_error",
+			"ref":     msgID,
+			"seq":     cmd["seq"],
+			"code":    code,
+			"message": err.Error(),
+		}
+		if code == "stale_seq" {
+			reply["stored_seq"] = stored
+		}
+		_ = ec.send(reply)
+		return
+	}
+	_ = ec.send(map[string]interface{}{
+		"type": "answer_ack",
+		"ref":  msgID,
+		"seq":  stored,
+	})
+}
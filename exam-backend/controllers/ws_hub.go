@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"exam-backend/database"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Close codes sent to a candidate socket the server shuts down on purpose.
+const (
+	closeReplaced   = 4001 // another connection for the same attempt took over
+	closeTerminated = 4002 // attempt terminated by a proctor
+)
+
+// examConn wraps a candidate socket so server-initiated messages (pause, resume, ...)
+// can be written from other goroutines. gorilla/websocket allows one writer at a time.
+type examConn struct {
+	id       string // unique per connection; stored in ws_active:<attempt>
+	protocol int    // wsProtocolV1 / wsProtocolV2 (see ws_protocol.go)
+	conn     *websocket.Conn
+	mu       sync.Mutex
+
+	closedBy string // set when the server closes the socket (replaced, terminated)
+}
+
+func newExamConn(conn *websocket.Conn, protocol int) *examConn {
+	return &examConn{id: uuid.New().String(), protocol: protocol, conn: conn}
+}
+
+func (e *examConn) writeText(msg string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_ = e.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	return e.conn.WriteMessage(websocket.TextMessage, []byte(msg))
+}
+
+func (e *examConn) writeJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return e.writeText(string(data))
+}
+
+// shutdown sends a close frame and closes the socket; the read loop then exits
+// and can tell from closeReason why.
+func (e *examConn) shutdown(code int, reason string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closedBy != "" {
+		return
+	}
+	e.closedBy = reason
+	_ = e.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(2*time.Second))
+	_ = e.conn.Close()
+}
+
+func (e *examConn) closeReason() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.closedBy
+}
+
+// Sockets held by THIS process, keyed by attempt id.
+var examConns = struct {
+	sync.RWMutex
+	m map[string]*examConn
+}{m: map[string]*examConn{}}
+
+func localExamConn(attemptID string) *examConn {
+	examConns.RLock()
+	defer examConns.RUnlock()
+	return examConns.m[attemptID]
+}
+
+// registerExamConn makes ec the attempt's socket and kicks the previous one,
+// whether it lives in this process or on another replica.
+func registerExamConn(attemptID string, ec *examConn) {
+	examConns.Lock()
+	prev := examConns.m[attemptID]
+	examConns.m[attemptID] = ec
+	examConns.Unlock()
+
+	if prev != nil && prev != ec {
+		kickExamConn(prev)
+	}
+	publishExamControl(examControl{AttemptID: attemptID, Kind: "kick", ConnID: ec.id})
+}
+
+// unregisterExamConn only removes ec if it is still the registered socket
+// (a reconnect may already have replaced it).
+func unregisterExamConn(attemptID string, ec *examConn) {
+	examConns.Lock()
+	if examConns.m[attemptID] == ec {
+		delete(examConns.m, attemptID)
+	}
+	examConns.Unlock()
+}
+
+func kickExamConn(ec *examConn) {
+	_ = ec.send(map[string]interface{}{
+		"type":   "kicked",
+		"reason": "replaced_by_new_connection",
+	})
+	ec.shutdown(closeReplaced, "replaced_by_new_connection")
+}
+
+// ---- cross-replica fan-out ----
+
+// Every replica subscribes to one channel; the replica holding the attempt's
+// socket acts on the message, the others ignore it.
+const examControlChannel = "ws:exam:control"
+
+type examControl struct {
+	AttemptID string                 `json:"attempt_id"`
+	Kind      string                 `json:"kind"`              // send, kick, close
+	ConnID    string                 `json:"conn_id,omitempty"` // kick: the connection that stays
+	Message   map[string]interface{} `json:"message,omitempty"` // send/close: framed per the socket's protocol
+	Legacy    string                 `json:"legacy,omitempty"`  // close: v1 text frame
+	Reason    string                 `json:"reason,omitempty"`  // close: close-frame reason
+}
+
+func publishExamControl(msg examControl) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	if err := database.RedisPublish(context.Background(), examControlChannel, string(data)); err != nil {
+		log.Printf("ws control publish failed for attempt %s: %v", msg.AttemptID, err)
+	}
+}
+
+func handleExamControl(msg examControl) {
+	ec := localExamConn(msg.AttemptID)
+	if ec == nil {
+		return
+	}
+	switch msg.Kind {
+	case "kick":
+		// ws_active holds the newest connection; a late kick must not hit it
+		if current, _ := redisGet("ws_active:" + msg.AttemptID); ec.id != msg.ConnID && current != ec.id {
+			unregisterExamConn(msg.AttemptID, ec)
+			kickExamConn(ec)
+		}
+	case "send":
+		_ = ec.send(msg.Message)
+	case "close":
+		finalFrames(ec, msg.Message, msg.Legacy)
+		ec.shutdown(closeTerminated, msg.Reason)
+	}
+}
+
+// StartExamControlListener must run on every replica that serves /ws/exam.
+func StartExamControlListener() {
+	sub := database.RedisSubscribe(context.Background(), examControlChannel)
+	go func() {
+		for m := range sub.Channel() {
+			var msg examControl
+			if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+				continue
+			}
+			handleExamControl(msg)
+		}
+	}()
+}
+
+// attemptOnline reports whether any replica currently holds a socket for the attempt.
+func attemptOnline(attemptID string) bool {
+	val, _ := redisGet("ws_active:" + attemptID)
+	return val != ""
+}
+
+// sendToAttempt pushes a message (with its "type") to the attempt's socket wherever it lives.
+// Returns false when the candidate is not connected.
+func sendToAttempt(attemptID string, msg map[string]interface{}) bool {
+	if ec := localExamConn(attemptID); ec != nil {
+		return ec.send(msg) == nil
+	}
+	if !attemptOnline(attemptID) {
+		return false
+	}
+	publishExamControl(examControl{AttemptID: attemptID, Kind: "send", Message: msg})
+	return true
+}
+
+// closeAttemptSocket writes the final message and closes the attempt's socket wherever it lives.
+// legacy is the text frame v1 clients expect instead (e.g. "terminated:proctor").
+func closeAttemptSocket(attemptID, reason string, msg map[string]interface{}, legacy string) {
+	if ec := localExamConn(attemptID); ec != nil {
+		finalFrames(ec, msg, legacy)
+		ec.shutdown(closeTerminated, reason)
+		return
+	}
+	publishExamControl(examControl{AttemptID: attemptID, Kind: "close", Message: msg, Legacy: legacy, Reason: reason})
+}
+
+func finalFrames(ec *examConn, msg map[string]interface{}, legacy string) {
+	if msg != nil {
+		_ = ec.send(msg)
+	}
+	if legacy != "" {
+		_ = ec.sendLegacyText(legacy)
+	}
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// /ws/exam message protocol (see WS_PROTOCOL.md).
+//
+// v1 is what the current client speaks and stays the default: text "ping" -> "pong",
+// flat JSON commands {"type": ...} and the "terminated:<event>" text frame.
+// v2 is selected with ?protocol=2: every frame in both directions is an envelope,
+// client messages that carry an id are acknowledged, unknown types get an error frame.
+const (
+	wsProtocolV1 = 1
+	wsProtocolV2 = 2
+
+	// wsMaxMessageBytes is the largest frame a client may send; bigger frames close
+	// the socket with 1009 (message too big). Announced in the v2 hello frame.
+	wsMaxMessageBytes = 16 << 10
+)
+
+type wsEnvelope struct {
+	V       int                    `json:"v"`
+	Type    string                 `json:"type"`
+	ID      string                 `json:"id,omitempty"`
+	Ts      int64                  `json:"ts"` // unix milliseconds
+	Payload map[string]interface{} `json:"payload,omitempty"`
+}
+
+var errMissingType = errors.New("message has no type")
+
+func parseProtocolVersion(raw string) int {
+	if v, err := strconv.Atoi(raw); err == nil && v >= wsProtocolV2 {
+		return wsProtocolV2
+	}
+	return wsProtocolV1
+}
+
+// send writes a server message. msg carries its type under "type"; v1 clients get it
+// flat, v2 clients get the rest of msg as the envelope payload.
+func (e *examConn) send(msg map[string]interface{}) error {
+	if e.protocol < wsProtocolV2 {
+		return e.writeJSON(msg)
+	}
+	msgType, _ := msg["type"].(string)
+	payload := make(map[string]interface{}, len(msg))
+	for k, v := range msg {
+		if k != "type" {
+			payload[k] = v
+		}
+	}
+	return e.writeJSON(wsEnvelope{
+		V:       wsProtocolV2,
+		Type:    msgType,
+		ID:      uuid.New().String(),
+		Ts:      time.Now().UnixMilli(),
+		Payload: payload,
+	})
+}
+
+// sendLegacyText writes a v1-only text frame ("pong", "terminated:..."); v2 clients
+// receive the structured equivalent instead.
+func (e *examConn) sendLegacyText(msg string) error {
+	if e.protocol >= wsProtocolV2 {
+		return nil
+	}
+	return e.writeText(msg)
+}
+
+// ack confirms a v2 client message by id.
+func (e *examConn) ack(refID string, extra map[string]interface{}) {
+	if e.protocol < wsProtocolV2 || refID == "" {
+		return
+	}
+	msg := map[string]interface{}{"type": "ack", "ref": refID}
+	for k, v := range extra {
+		msg[k] = v
+	}
+	_ = e.send(msg)
+}
+
+// sendError reports a rejected client message. v1 clients never got error frames.
+func (e *examConn) sendError(refID, code, message string) {
+	if e.protocol < wsProtocolV2 {
+		return
+	}
+	_ = e.send(map[string]interface{}{
+		"type":    "error",
+		"ref":     refID,
+		"code":    code,
+		"message": message,
+	})
+}
+
+// decodeClientMessage turns a frame into the flat command map the handlers use
+// (type, ts and payload fields at the top level) plus the client's message id.
+func decodeClientMessage(protocol int, raw []byte) (map[string]interface{}, string, error) {
+	if protocol < wsProtocolV2 {
+		var cmd map[string]interface{}
+		if err := json.Unmarshal(raw, &cmd); err != nil {
+			return nil, "", err
+		}
+		if t, _ := cmd["type"].(string); t == "" {
+			return cmd, "", errMissingType
+		}
+		return cmd, "", nil
+	}
+
+	var env wsEnvelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil, "", err
+	}
+	cmd := env.Payload
+	if cmd == nil {
+		cmd = map[string]interface{}{}
+	}
+	cmd["type"] = env.Type
+	if env.Ts > 0 {
+		cmd["ts"] = float64(env.Ts)
+	}
+	if env.Type == "" {
+		return cmd, env.ID, errMissingType
+	}
+	return cmd, env.ID, nil
+}
+
+// answerSeq is the last persisted autosave seq; the client's next delta must be above it.
+func helloMessage(heartbeatInterval time.Duration, answerSeq int64) map[string]interface{} {
+	return map[string]interface{}{
+		"type":                  "hello",
+		"protocol":              wsProtocolV2,
+		"max_message_bytes":     wsMaxMessageBytes,
+		"heartbeat_interval_ms": heartbeatInterval.Milliseconds(),
+		"answer_seq":            answerSeq,
+	}
+}
+
+// timeSyncMessage carries the authoritative clock so the client can correct drift.
+func timeSyncMessage(timeLeft int64) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "time_sync",
+		"server_time": time.Now().UnixMilli(),
+		"time_left":   timeLeft,
+	}
+}
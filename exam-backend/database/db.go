@@ -14,7 +14,7 @@ var DB *gorm.DB
 
 func Connect() {
 	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=Asia/Kolkata",
+		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
 		os.Getenv("DB_HOST"),
 		os.Getenv("DB_USER"),
 		os.Getenv("DB_PASSWORD"),
@@ -38,5 +38,5 @@ func Connect() {
 	sqlDB.SetConnMaxLifetime(30 * time.Minute)
 
 	DB = db
-	log.Println("✅ Database connected (Asia/Kolkata timezone)")
+	log.Println("✅ Database connected (UTC session time zone)")
 }
@@ -53,6 +53,12 @@ func RedisDel(ctx context.Context, key string) error {
 
 // -------------------- ATOMIC HELPERS --------------------
 
+// RedisSetNX sets the key only if it does not exist yet; reports whether it was set
+func RedisSetNX(ctx context.Context, key string, val string, ttl time.Duration) (bool, error) {
+	ensureRedis()
+	return redisClient.SetNX(ctx, key, val, ttl).Result()
+}
+
 // RedisIncr increments a key atomically
 func RedisIncr(ctx context.Context, key string) (int64, error) {
 	ensureRedis()
@@ -65,6 +71,27 @@ func RedisExpire(ctx context.Context, key string, ttl time.Duration) error {
 	return redisClient.Expire(ctx, key, ttl).Err()
 }
 
+// RedisGetDel reads and removes a key in one step, for single-use values
+func RedisGetDel(ctx context.Context, key string) (string, error) {
+	ensureRedis()
+	var get *redis.StringCmd
+	_, err := redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return get.Val(), nil
+}
+
+// RedisTTL returns the remaining TTL of a key
+func RedisTTL(ctx context.Context, key string) (time.Duration, error) {
+	ensureRedis()
+	return redisClient.TTL(ctx, key).Result()
+}
+
 // -------------------- HEALTH --------------------
 
 func RedisHealthCheck(ctx context.Context) error {
@@ -102,3 +129,86 @@ func RedisKeys(ctx context.Context, pattern string) ([]string, error) {
 	return redisClient.Keys(ctx, pattern).Result()
 }
 
+func RedisMGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	ensureRedis()
+	if len(keys) == 0 {
+		return []interface{}{}, nil
+	}
+	return redisClient.MGet(ctx, keys...).Result()
+}
+
+var delIfEqualsScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// RedisDelIfEquals deletes key only while it still holds val, so a stale owner
+// can't remove a value written by its successor.
+func RedisDelIfEquals(ctx context.Context, key string, val string) error {
+	ensureRedis()
+	return delIfEqualsScript.Run(ctx, redisClient, []string{key}, val).Err()
+}
+
+// -------------------- AUTOSAVE --------------------
+
+// mergeJSONMapScript merges fields into the JSON object stored at KEYS[1] unless
+// ARGV[1] is not newer than the sequence number stored at KEYS[2].
+// Returns {stored sequence, number of fields in the object, 1 if applied else 0}.
+var mergeJSONMapScript = redis.NewScript(`
+local last = tonumber(redis.call("GET", KEYS[2]) or "0")
+local seq = tonumber(ARGV[1])
+local current = redis.call("GET", KEYS[1])
+local obj = {}
+if current then
+	obj = cjson.decode(current)
+end
+local count = 0
+if seq <= last then
+	for _ in pairs(obj) do count = count + 1 end
+	return {last, count, 0}
+end
+local fields = cjson.decode(ARGV[3])
+for k, v in pairs(fields) do
+	obj[k] = v
+end
+for _ in pairs(obj) do count = count + 1 end
+redis.call("SET", KEYS[1], cjson.encode(obj), "PX", ARGV[2])
+redis.call("SET", KEYS[2], seq, "PX", ARGV[2])
+return {seq, count, 1}`)
+
+// RedisMergeJSONMap applies per-field updates to a JSON object key atomically.
+// seq must increase per writer; a seq at or below the persisted one is ignored.
+// Returns the last persisted seq, the object's field count and whether this
+// call's fields were applied.
+func RedisMergeJSONMap(ctx context.Context, key, seqKey string, seq int64, fields map[string]string, ttl time.Duration) (int64, int64, bool, error) {
+	ensureRedis()
+	if fields == nil {
+		fields = map[string]string{}
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	res, err := mergeJSONMapScript.Run(ctx, redisClient, []string{key, seqKey}, seq, ttl.Milliseconds(), string(data)).Int64Slice()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if len(res) != 3 {
+		return 0, 0, false, fmt.Errorf("unexpected autosave script result: %v", res)
+	}
+	return res[0], res[1], res[2] == 1, nil
+}
+
+// -------------------- PUB/SUB --------------------
+
+func RedisPublish(ctx context.Context, channel string, message interface{}) error {
+	ensureRedis()
+	return redisClient.Publish(ctx, channel, message).Err()
+}
+
+// RedisSubscribe returns a subscription; the caller must Close() it.
+func RedisSubscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	ensureRedis()
+	return redisClient.Subscribe(ctx, channels...)
+}
@@ -0,0 +1,20 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// ConsoleMailer logs messages instead of sending them; meant for development.
+type ConsoleMailer struct {
+	From string
+}
+
+func (m *ConsoleMailer) Send(ctx context.Context, msg Message) error {
+	if !validAddress(msg.To) {
+		return fmt.Errorf("invalid recipient %q", msg.To)
+	}
+	log.Printf("📧 mail to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
@@ -0,0 +1,39 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileMailer writes every message as an .eml file into Dir, for local testing
+// and for environments where another process picks up outgoing mail.
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileMailer{Dir: dir, From: from}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if !validAddress(msg.To) {
+		return fmt.Errorf("invalid recipient %q", msg.To)
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), uuid.New().String()[:8])
+	path := filepath.Join(m.Dir, name)
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, rfc822(m.From, msg), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,71 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+// Mailer delivers account emails (password reset, email verification).
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Default is the mailer used by the controllers; set by Init.
+var Default Mailer
+
+// Init selects the backend from MAIL_DRIVER ("console" by default).
+// console: logs the message; file: writes .eml files under MAIL_DIR (default ./data/mail);
+// smtp: SMTP_HOST, SMTP_PORT (default 587), SMTP_USERNAME, SMTP_PASSWORD.
+// MAIL_FROM sets the sender for every driver.
+func Init() error {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	kind := strings.ToLower(os.Getenv("MAIL_DRIVER"))
+	switch kind {
+	case "", "console":
+		Default = &ConsoleMailer{From: from}
+		return nil
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "./data/mail"
+		}
+		m, err := NewFileMailer(dir, from)
+		if err != nil {
+			return err
+		}
+		Default = m
+		return nil
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return fmt.Errorf("MAIL_DRIVER=smtp requires SMTP_HOST")
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		Default = &SMTPMailer{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported MAIL_DRIVER %q (supported: console, file, smtp)", kind)
+	}
+}
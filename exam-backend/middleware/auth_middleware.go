@@ -1,17 +1,47 @@
 package middleware
 
 import (
-	"exam-backend/database"
+	"context"
+	"errors"
 	"exam-backend/models"
-	"fmt"
-	"net/http"
-	"os"
+	"exam-backend/signing"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+var (
+	ErrInvalidToken     = errors.New("Invalid or expired token")
+	ErrSessionNotActive = errors.New("session_not_active_or_invalid")
+)
+
+// ValidateToken verifies the JWT against the signing key set (kid and alg must
+// match a known key) and checks its JTI against user_sessions.
+// Shared by AuthMiddleware and handlers that cannot use headers (websockets).
+func ValidateToken(tokenString string) (*models.Claims, error) {
+	claims := &models.Claims{}
+	ks := signing.Current
+	token, err := jwt.ParseWithClaims(tokenString, claims, ks.Keyfunc,
+		jwt.WithValidMethods(ks.Methods()),
+		jwt.WithIssuer(signing.Issuer()),
+		jwt.WithExpirationRequired(),
+	)
+
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	// validate JTI (token id) exists and is active in user_sessions (cached in Redis)
+	if jti := claims.ID; jti != "" {
+		if !sessionActive(context.Background(), jti) {
+			return nil, ErrSessionNotActive
+		}
+	}
+
+	return claims, nil
+}
+
 // AuthMiddleware validates JWT and ensures the token's JTI is active in user_sessions.
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -25,13 +55,9 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		tokenString = strings.TrimPrefix(tokenString, "Bearer ")
 
-		claims := &models.Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(401, gin.H{"error": "Invalid or expired token"})
+		claims, err := ValidateToken(tokenString)
+		if err != nil {
+			c.JSON(401, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
@@ -39,48 +65,11 @@ func AuthMiddleware() gin.HandlerFunc {
 		// STORE USER ID + ROLE IN CONTEXT
 		c.Set("userID", claims.UserID)
 		c.Set("role", claims.Role)
+		c.Set("mfa", claims.MFA)
 
-		// validate JTI (token id) exists and is active in user_sessions
-		jti := claims.ID
-		if jti != "" {
-			var sess models.UserSession
-			if err := database.DB.Where("jti = ? AND active = true", jti).First(&sess).Error; err != nil {
-				// If not found or DB error -> reject
-				c.JSON(401, gin.H{"error": "session_not_active_or_invalid"})
-				c.Abort()
-				return
-			}
-			// store jti in context for downstream use
-			c.Set("jti", jti)
-		}
-
-		c.Next()
-	}
-}
-
-// AdminOnly middleware
-func AdminOnly() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		roleVal, _ := c.Get("role")
-		role := fmt.Sprint(roleVal)
-
-		if role != "admin" {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
-			return
-		}
-		c.Next()
-	}
-}
-
-// TeacherOnly middleware
-func TeacherOnly() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		role := c.GetString("role")
-
-		if role != "teacher" {
-			c.JSON(403, gin.H{"error": "Only teachers can access this"})
-			c.Abort()
-			return
+		// store jti in context for downstream use
+		if claims.ID != "" {
+			c.Set("jti", claims.ID)
 		}
 
 		c.Next()
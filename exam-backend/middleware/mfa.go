@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MFA_REQUIRED_ROLES: comma-separated roles that must enrol in TOTP and complete
+// it at login, e.g. "admin,teacher" (empty = enrolment is optional for everyone).
+
+// MFARequiredForRole reports whether MFA_REQUIRED_ROLES lists role.
+func MFARequiredForRole(role string) bool {
+	for _, r := range strings.Split(os.Getenv("MFA_REQUIRED_ROLES"), ",") {
+		if strings.EqualFold(strings.TrimSpace(r), role) && role != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// MFARequiredForUser reports whether any role the user holds, the primary one
+// or one assigned through user_roles, is listed in MFA_REQUIRED_ROLES. A student
+// given staff roles must not get past enforcement on their primary role.
+func MFARequiredForUser(ctx context.Context, userID string) (bool, error) {
+	if strings.TrimSpace(os.Getenv("MFA_REQUIRED_ROLES")) == "" {
+		return false, nil
+	}
+	roles, err := UserRoles(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, role := range roles {
+		if MFARequiredForRole(role) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RequireMFA rejects sessions that did not complete a second factor when one of
+// the caller's roles is listed in MFA_REQUIRED_ROLES. Users who enrolled
+// voluntarily always get an MFA session from Login, so they pass as well.
+func RequireMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("mfa") {
+			c.Next()
+			return
+		}
+		required, err := MFARequiredForUser(c.Request.Context(), c.GetString("userID"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to load roles"})
+			return
+		}
+		if required {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "mfa_required"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"context"
+	"exam-backend/database"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A user's permissions are the union of their primary role (users.role) and any
+// extra roles in user_roles. They are read from the database and cached in Redis
+// for a minute; every role or assignment change bumps a version number that is
+// part of the cache key, so edits apply on the next request.
+
+const permissionCacheTTL = time.Minute
+
+const permissionVersionKey = "perms:version"
+
+func permissionCacheKey(ctx context.Context, userID string) string {
+	version, _ := database.RedisGet(ctx, permissionVersionKey)
+	return fmt.Sprintf("perms:%s:%s", version, userID)
+}
+
+// UserPermissions returns the permission set of a user.
+func UserPermissions(ctx context.Context, userID string) (map[string]bool, error) {
+	key := permissionCacheKey(ctx, userID)
+
+	var perms []string
+	if err := database.RedisGetJSON(ctx, key, &perms); err != nil {
+		err := database.DB.Raw(`
+			SELECT DISTINCT rp.permission
+			FROM role_permissions rp
+			JOIN roles r ON r.id = rp.role_id
+			WHERE r.name = (SELECT role FROM users WHERE id = ?)
+			   OR r.id IN (SELECT role_id FROM user_roles WHERE user_id = ?)`,
+			userID, userID).Scan(&perms).Error
+		if err != nil {
+			return nil, err
+		}
+		_ = database.RedisSetJSON(ctx, key, perms, permissionCacheTTL)
+	}
+
+	set := make(map[string]bool, len(perms))
+	for _, p := range perms {
+		set[p] = true
+	}
+	return set, nil
+}
+
+// UserRoles returns the names of every role the user holds: the primary one
+// plus any assigned through user_roles. Cached like permissions.
+func UserRoles(ctx context.Context, userID string) ([]string, error) {
+	version, _ := database.RedisGet(ctx, permissionVersionKey)
+	key := fmt.Sprintf("roles:%s:%s", version, userID)
+
+	var roles []string
+	if err := database.RedisGetJSON(ctx, key, &roles); err != nil {
+		err := database.DB.Raw(`
+			SELECT role FROM users WHERE id = ?
+			UNION
+			SELECT r.name FROM roles r JOIN user_roles ur ON ur.role_id = r.id WHERE ur.user_id = ?`,
+			userID, userID).Scan(&roles).Error
+		if err != nil {
+			return nil, err
+		}
+		_ = database.RedisSetJSON(ctx, key, roles, permissionCacheTTL)
+	}
+	return roles, nil
+}
+
+// InvalidatePermissions drops every cached permission set (and role list).
+func InvalidatePermissions(ctx context.Context) {
+	_, _ = database.RedisIncr(ctx, permissionVersionKey)
+}
+
+// contextPermissions loads the caller's permissions once per request.
+func contextPermissions(c *gin.Context) (map[string]bool, error) {
+	if v, ok := c.Get("permissions"); ok {
+		return v.(map[string]bool), nil
+	}
+	perms, err := UserPermissions(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		return nil, err
+	}
+	c.Set("permissions", perms)
+	return perms, nil
+}
+
+// HasPermission is for handlers that branch on a permission instead of
+// rejecting the request outright.
+func HasPermission(c *gin.Context, perm string) bool {
+	perms, err := contextPermissions(c)
+	return err == nil && perms[perm]
+}
+
+// RequirePermission rejects callers that lack any of perms.
+func RequirePermission(perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		have, err := contextPermissions(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+			return
+		}
+		for _, p := range perms {
+			if !have[p] {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "permission_denied", "permission": p})
+				return
+			}
+		}
+		c.Next()
+	}
+}
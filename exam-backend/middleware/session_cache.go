@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"exam-backend/database"
+	"exam-backend/models"
+	"time"
+)
+
+// Session state is cached in Redis so AuthMiddleware doesn't query user_sessions on
+// every request. Revocation overwrites the entry, so it applies to the next request.
+
+const sessionCacheTTL = 5 * time.Minute
+
+// revokedTTL outlives any access token signed for the session.
+const revokedTTL = 24 * time.Hour
+
+func sessionCacheKey(jti string) string {
+	return "session:" + jti
+}
+
+// sessionActive answers from the cache, falling back to the DB (and filling the cache).
+func sessionActive(ctx context.Context, jti string) bool {
+	if val, err := database.RedisGet(ctx, sessionCacheKey(jti)); err == nil {
+		return val == "active"
+	}
+
+	var sess models.UserSession
+	if err := database.DB.Select("id", "active").Where("jti = ? AND active = true", jti).First(&sess).Error; err != nil {
+		// If not found or DB error -> reject
+		return false
+	}
+	// SETNX: a revocation that landed since the DB read must not be overwritten
+	if set, err := database.RedisSetNX(ctx, sessionCacheKey(jti), "active", sessionCacheTTL); err == nil && !set {
+		val, err := database.RedisGet(ctx, sessionCacheKey(jti))
+		return err == nil && val == "active"
+	}
+	return true
+}
+
+// MarkSessionsRevoked must be called whenever user_sessions rows are deactivated.
+func MarkSessionsRevoked(ctx context.Context, jtis ...string) {
+	for _, jti := range jtis {
+		if jti != "" {
+			_ = database.RedisSet(ctx, sessionCacheKey(jti), "revoked", revokedTTL)
+		}
+	}
+}
@@ -5,5 +5,6 @@ import "github.com/golang-jwt/jwt/v5"
 type Claims struct {
 	UserID string `json:"userId"`
 	Role   string `json:"role"`
+	MFA    bool   `json:"mfa,omitempty"` // session completed a second factor
 	jwt.RegisteredClaims
 }
@@ -8,12 +8,21 @@ import (
 )
 
 type User struct {
-	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
-	Email     string    `gorm:"uniqueIndex;not null" json:"email"`
-	Password  string    `json:"-"`
-	FullName  string    `json:"full_name"`
-	Role      string    `gorm:"default:'student'" json:"role"`
-	CreatedAt time.Time `json:"created_at"`
+	ID                uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	Email             string     `gorm:"uniqueIndex;not null" json:"email"`
+	Password          string     `json:"-"`
+	FullName          string     `json:"full_name"`
+	Role              string     `gorm:"default:'student'" json:"role"`
+	RollNumber        string     `gorm:"index;size:64" json:"roll_number,omitempty"`
+	EmailVerifiedAt   *time.Time `json:"email_verified_at,omitempty"`
+	SelfRegistered    bool       `gorm:"default:false" json:"self_registered,omitempty"` // signed up via /api/auth/register
+	MFAEnabled        bool       `gorm:"default:false" json:"mfa_enabled"`
+	MFASecret         string     `json:"-"` // AES-GCM sealed TOTP secret
+	MFALastCounter    int64      `json:"-"` // last accepted TOTP step, blocks replays
+	FailedLogins      int        `gorm:"default:0" json:"-"`
+	LastFailedLoginAt *time.Time `json:"-"`
+	LockedUntil       *time.Time `json:"-"`
+	CreatedAt         time.Time  `json:"created_at"`
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
@@ -32,8 +41,11 @@ type Exam struct {
 	IsActive        bool      `gorm:"default:true" json:"is_active"`
 	Subject         string    `json:"subject"`
 
+	// Stored in UTC; TimeZone (IANA name, e.g. "Asia/Kolkata") is used for
+	// scheduling input and for rendering these back with the exam's offset.
 	StartTime time.Time `json:"start_time"`
 	EndTime   time.Time `json:"end_time"`
+	TimeZone  string    `gorm:"size:64" json:"time_zone"`
 
 	// Positive Marks Configuration
 	MarksEasy   int `json:"marks_easy"`
@@ -49,9 +61,25 @@ type Exam struct {
 	// Optional: Section locking like TCS iON
 	SectionLocking bool `json:"section_locking"`
 
+	// Access control (centre-based exams). Never sent to students as-is;
+	// admins get them through AdminGetExam / the access-code endpoints.
+	AccessCode   string   `json:"-"`
+	AllowedCIDRs []string `gorm:"serializer:json" json:"-"`
+
+	// Proctoring rules; nil means DefaultViolationPolicy(). Kept from students so
+	// thresholds can't be gamed; admins get it through AdminGetExam / violation-policy.
+	ViolationPolicy *ViolationPolicy `gorm:"serializer:json" json:"-"`
+
 	CreatedByID uuid.UUID  `json:"created_by"`
 	CreatedAt   time.Time  `json:"created_at"`
 	Questions   []Question `gorm:"foreignKey:ExamID;constraint:OnDelete:CASCADE;" json:"questions,omitempty"`
+
+	// Groups the exam is assigned to. An exam with no groups is open to every student.
+	Groups []StudentGroup `gorm:"many2many:exam_groups;constraint:OnDelete:CASCADE;" json:"groups,omitempty"`
+
+	// Shifts. When an exam has slots, each student sits it in their booked slot's window.
+	Slots            []ExamSlot `gorm:"foreignKey:ExamID;constraint:OnDelete:CASCADE;" json:"slots,omitempty"`
+	AllowSelfBooking bool       `gorm:"default:false" json:"allow_self_booking"`
 }
 
 func (e *Exam) BeforeCreate(tx *gorm.DB) (err error) {
@@ -61,6 +89,67 @@ func (e *Exam) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+func (e *Exam) EffectiveViolationPolicy() ViolationPolicy {
+	if e.ViolationPolicy == nil {
+		return DefaultViolationPolicy()
+	}
+	return *e.ViolationPolicy
+}
+
+// StudentGroup is a class / batch / cohort of students.
+type StudentGroup struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	Name        string    `gorm:"uniqueIndex;not null" json:"name"`
+	Description string    `json:"description"`
+	CreatedByID uuid.UUID `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	Members     []User    `gorm:"many2many:student_group_members;constraint:OnDelete:CASCADE;" json:"members,omitempty"`
+}
+
+func (g *StudentGroup) BeforeCreate(tx *gorm.DB) (err error) {
+	if g.ID == uuid.Nil {
+		g.ID = uuid.New()
+	}
+	return
+}
+
+// ExamSlot is one sitting (shift) of an exam.
+type ExamSlot struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	ExamID    uuid.UUID `gorm:"type:uuid;index" json:"exam_id"`
+	Label     string    `json:"label"` // e.g. "Morning shift"
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Capacity  int       `json:"capacity"` // 0 = unlimited
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *ExamSlot) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return
+}
+
+// SlotBooking assigns a student to a slot. One booking per (exam, student).
+type SlotBooking struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	ExamID     uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_booking_exam_student" json:"exam_id"`
+	StudentID  uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_booking_exam_student" json:"student_id"`
+	Student    User      `gorm:"foreignKey:StudentID" json:"student,omitempty"`
+	SlotID     uuid.UUID `gorm:"type:uuid;index" json:"slot_id"`
+	Slot       ExamSlot  `gorm:"foreignKey:SlotID;constraint:OnDelete:CASCADE;" json:"slot,omitempty"`
+	BookedByID uuid.UUID `json:"booked_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (b *SlotBooking) BeforeCreate(tx *gorm.DB) (err error) {
+	if b.ID == uuid.Nil {
+		b.ID = uuid.New()
+	}
+	return
+}
+
 type Question struct {
 	ID            uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
 	ExamID        uuid.UUID `json:"exam_id"`
@@ -98,6 +187,10 @@ type ExamAttempt struct {
 	StudentID uuid.UUID `json:"student_id"`
 	Student   User      `gorm:"foreignKey:StudentID" json:"student,omitempty"`
 
+	// Slot the attempt was started in (nil for single-window exams)
+	SlotID *uuid.UUID `gorm:"type:uuid" json:"slot_id"`
+	Slot   *ExamSlot  `gorm:"foreignKey:SlotID" json:"slot,omitempty"`
+
 	StartedAt   time.Time  `json:"started_at"`
 	SubmittedAt *time.Time `json:"submitted_at"`
 	Score       int        `json:"score"`
@@ -107,13 +200,143 @@ type ExamAttempt struct {
 	IsTerminated      bool   `gorm:"default:false" json:"is_terminated"`
 	TerminationReason string `json:"termination_reason"`
 
+	// Proctor pause: the clock is frozen while PausedAt is set.
+	// PausedSeconds accumulates completed pauses.
+	PausedAt      *time.Time `json:"paused_at"`
+	PausedSeconds int        `gorm:"default:0" json:"paused_seconds"`
+	PauseReason   string     `json:"pause_reason"`
+
 	TabSwitches int               `json:"tab_switches"`
 	Answers     map[string]string `gorm:"serializer:json" json:"answers"`
 	Snapshots   []string          `gorm:"serializer:json" json:"snapshots"`
 
+	// Per-event violation counters and review flag driven by the exam's ViolationPolicy
+	ViolationCounts  map[string]int `gorm:"serializer:json" json:"violation_counts"`
+	FlaggedForReview bool           `gorm:"default:false" json:"flagged_for_review"`
+	FlagReasons      []string       `gorm:"serializer:json" json:"flag_reasons"`
+
+	// Outcome of the latest proctor review of a termination: "", "confirmed" or "reinstated".
+	// The full history is in AttemptReview.
+	ReviewStatus string `gorm:"size:20" json:"review_status"`
+
 	TimeLeftSeconds int `gorm:"-" json:"time_left"`
 }
 
+// AttemptSnapshot is a webcam image uploaded during an attempt. The image and its
+// thumbnail live in the blob store; only their keys are kept here.
+type AttemptSnapshot struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	AttemptID   uuid.UUID  `gorm:"type:uuid;index:idx_snapshot_attempt_time,priority:1" json:"attempt_id"`
+	ExamID      uuid.UUID  `gorm:"type:uuid;index" json:"exam_id"`
+	StorageKey  string     `json:"-"`
+	ThumbKey    string     `json:"-"`
+	ContentType string     `gorm:"size:50" json:"content_type"`
+	SizeBytes   int64      `json:"size_bytes"`
+	Width       int        `json:"width"`
+	Height      int        `json:"height"`
+	CapturedAt  *time.Time `json:"captured_at"` // client clock, if sent
+	CreatedAt   time.Time  `gorm:"index:idx_snapshot_attempt_time,priority:2" json:"created_at"`
+}
+
+func (s *AttemptSnapshot) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return
+}
+
+// Decisions a proctor can take on a terminated attempt.
+const (
+	ReviewDecisionConfirm   = "confirmed"
+	ReviewDecisionReinstate = "reinstated"
+)
+
+// AttemptReview records one proctor decision on a terminated attempt.
+type AttemptReview struct {
+	ID                uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	AttemptID         uuid.UUID `gorm:"type:uuid;index" json:"attempt_id"`
+	Decision          string    `gorm:"size:20" json:"decision"`
+	Reason            string    `json:"reason"`
+	TerminationReason string    `json:"termination_reason"` // what the attempt was terminated for
+	TimeLeftSeconds   int64     `json:"time_left_seconds"`  // remaining time given back on reinstate
+	ReviewerID        uuid.UUID `gorm:"type:uuid" json:"reviewer_id"`
+	Reviewer          User      `gorm:"foreignKey:ReviewerID" json:"reviewer,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+func (r *AttemptReview) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return
+}
+
+// AttemptEvent is one proctoring signal or connection lifecycle event of an attempt,
+// as received on /ws/exam.
+type AttemptEvent struct {
+	ID          uuid.UUID              `gorm:"type:uuid;primaryKey" json:"id"`
+	AttemptID   uuid.UUID              `gorm:"type:uuid;index:idx_attempt_event_time,priority:1" json:"attempt_id"`
+	ExamID      uuid.UUID              `gorm:"type:uuid;index" json:"exam_id"`
+	Type        string                 `gorm:"size:50;index" json:"type"` // "connected", "disconnected", "tab-switch", ...
+	Payload     map[string]interface{} `gorm:"serializer:json" json:"payload"`
+	ClientTime  *time.Time             `json:"client_time"`
+	ServerTime  time.Time              `gorm:"index:idx_attempt_event_time,priority:2" json:"server_time"`
+	IP          string                 `json:"ip"`
+	Fingerprint string                 `json:"fingerprint"`
+}
+
+func (e *AttemptEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return
+}
+
+// Announcement is a proctor message pushed to candidates during an exam.
+// An empty TargetAttemptIDs means every candidate of the exam.
+type Announcement struct {
+	ID               uuid.UUID   `gorm:"type:uuid;primaryKey" json:"id"`
+	ExamID           uuid.UUID   `gorm:"type:uuid;index" json:"exam_id"`
+	Message          string      `gorm:"type:text" json:"message"`
+	TargetAttemptIDs []uuid.UUID `gorm:"serializer:json" json:"target_attempt_ids"`
+	CreatedByID      uuid.UUID   `json:"created_by"`
+	CreatedAt        time.Time   `json:"created_at"`
+}
+
+func (a *Announcement) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return
+}
+
+// TargetsAttempt reports whether the announcement is addressed to the attempt.
+func (a *Announcement) TargetsAttempt(attemptID uuid.UUID) bool {
+	if len(a.TargetAttemptIDs) == 0 {
+		return true
+	}
+	for _, id := range a.TargetAttemptIDs {
+		if id == attemptID {
+			return true
+		}
+	}
+	return false
+}
+
+type AnnouncementAck struct {
+	ID             uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	AnnouncementID uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_announcement_ack" json:"announcement_id"`
+	AttemptID      uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_announcement_ack" json:"attempt_id"`
+	AckedAt        time.Time `json:"acked_at"`
+}
+
+func (a *AnnouncementAck) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return
+}
+
 type QuestionInput struct {
 	QuestionText   string  `json:"question_text"`
 	Type           string  `json:"type"`
@@ -135,6 +358,115 @@ type UserSession struct {
 	DeviceFingerprint string     `json:"device_fingerprint"`
 	IP                string     `json:"ip"`
 	Active            bool       `gorm:"default:true" json:"active"`
+	MFAVerified       bool       `gorm:"default:false" json:"mfa_verified"`
 	CreatedAt         time.Time  `json:"created_at"`
 	ExpiresAt         *time.Time `json:"expires_at"`
 }
+
+// UserInvite lets someone set their own password for an account an admin set up:
+// staff onboarding, or students from a bulk import. Only the SHA-256 of the invite
+// token is stored.
+type UserInvite struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	Email       string     `gorm:"index" json:"email"`
+	Role        string     `json:"role"`
+	FullName    string     `json:"full_name"`
+	RollNumber  string     `json:"roll_number"`
+	GroupID     *uuid.UUID `gorm:"type:uuid" json:"group_id"` // student group to join on accept
+	TokenHash   string     `gorm:"uniqueIndex;size:64" json:"-"`
+	InvitedByID uuid.UUID  `gorm:"type:uuid" json:"invited_by_id"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (i *UserInvite) BeforeCreate(tx *gorm.DB) (err error) {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return
+}
+
+// RefreshToken is one link in a session's rotation chain. Only the SHA-256 of the
+// token is stored. Presenting a token that was already used means it leaked, and
+// the whole session (the token family) is revoked.
+type RefreshToken struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	SessionID    uuid.UUID  `gorm:"type:uuid;index" json:"session_id"`
+	TokenHash    string     `gorm:"uniqueIndex;size:64" json:"-"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	UsedAt       *time.Time `json:"used_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *uuid.UUID `gorm:"type:uuid" json:"replaced_by_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (t *RefreshToken) BeforeCreate(tx *gorm.DB) (err error) {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return
+}
+
+// Purposes of AccountToken.
+const (
+	TokenPurposePasswordReset = "password_reset"
+	TokenPurposeVerifyEmail   = "verify_email"
+)
+
+// AccountToken backs the signed links sent by email. The link itself is a JWT
+// whose jti is this row's ID; the row makes it single-use and lets a newer link
+// (or a successful reset) invalidate older ones.
+type AccountToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;index" json:"user_id"`
+	Purpose   string     `gorm:"size:32;index" json:"purpose"`
+	Email     string     `json:"email"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (t *AccountToken) BeforeCreate(tx *gorm.DB) (err error) {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return
+}
+
+// MFARecoveryCode is a one-time fallback for a lost authenticator. Only the
+// SHA-256 of the code is stored.
+type MFARecoveryCode struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;index" json:"user_id"`
+	CodeHash  string     `gorm:"size:64;index" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (r *MFARecoveryCode) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return
+}
+
+// UserIdentity links a User to an account at an external OpenID provider. The
+// (issuer, subject) pair is the stable key; the email is kept for display only.
+type UserIdentity struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;index" json:"user_id"`
+	Issuer      string     `gorm:"size:255;uniqueIndex:idx_identity_issuer_subject" json:"issuer"`
+	Subject     string     `gorm:"size:255;uniqueIndex:idx_identity_issuer_subject" json:"subject"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (i *UserIdentity) BeforeCreate(tx *gorm.DB) (err error) {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return
+}
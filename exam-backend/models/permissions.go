@@ -0,0 +1,100 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Permissions checked by middleware.RequirePermission. Roles are sets of these,
+// stored in role_permissions so admins can change them without a deploy.
+const (
+	PermExamRead   = "exam:read"   // list exams and their slots in the staff views; answer keys also need exam:edit or bank:view
+	PermExamCreate = "exam:create" // create exams and browse the bank while building one
+	PermExamEdit   = "exam:edit"   // edit exam settings and violation policy
+	PermExamDelete = "exam:delete"
+	PermExamAssign = "exam:assign" // groups, slots, bookings and access codes
+
+	PermBankView = "bank:view"
+	PermBankEdit = "bank:edit"
+
+	PermAttemptGrade = "attempt:grade" // results, scores and submitted answers
+
+	PermProctorMonitor   = "proctor:monitor"   // live monitor, events, snapshots, violation reports
+	PermProctorIntervene = "proctor:intervene" // pause, resume, terminate, announcements
+	PermProctorReview    = "proctor:review"    // reinstate or confirm terminated attempts
+
+	PermGroupView   = "group:view"
+	PermGroupManage = "group:manage" // create, rename, membership
+	PermGroupDelete = "group:delete"
+
+	PermUserManage = "user:manage" // accounts, invites, imports, sessions, lockouts, MFA resets
+	PermRoleManage = "role:manage"
+)
+
+// AllPermissions lists every permission in display order.
+var AllPermissions = []string{
+	PermExamRead, PermExamCreate, PermExamEdit, PermExamDelete, PermExamAssign,
+	PermBankView, PermBankEdit,
+	PermAttemptGrade,
+	PermProctorMonitor, PermProctorIntervene, PermProctorReview,
+	PermGroupView, PermGroupManage, PermGroupDelete,
+	PermUserManage, PermRoleManage,
+}
+
+// IsPermission reports whether p is a known permission.
+func IsPermission(p string) bool {
+	for _, known := range AllPermissions {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Built-in roles. "admin" always holds every permission; the others are seeded
+// with DefaultRolePermissions once and can then be edited.
+const (
+	RoleStudent = "student"
+	RoleTeacher = "teacher"
+	RoleAdmin   = "admin"
+	RoleProctor = "proctor" // invigilator: watches and intervenes, cannot edit exams or see scores
+)
+
+var DefaultRolePermissions = map[string][]string{
+	RoleStudent: {},
+	RoleTeacher: {PermExamRead, PermBankView, PermBankEdit, PermGroupView, PermGroupManage},
+	RoleAdmin:   AllPermissions,
+	RoleProctor: {PermExamRead, PermProctorMonitor, PermProctorIntervene},
+}
+
+// Role is a named permission set. users.role holds a user's primary role (what
+// the frontend routes on); UserRole rows grant further roles on top of it.
+type Role struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	Name        string    `gorm:"uniqueIndex;size:64;not null" json:"name"`
+	Description string    `json:"description"`
+	BuiltIn     bool      `gorm:"default:false" json:"built_in"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (r *Role) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return
+}
+
+type RolePermission struct {
+	RoleID     uuid.UUID `gorm:"type:uuid;primaryKey" json:"role_id"`
+	Permission string    `gorm:"primaryKey;size:64" json:"permission"`
+}
+
+type UserRole struct {
+	UserID       uuid.UUID `gorm:"type:uuid;primaryKey" json:"user_id"`
+	RoleID       uuid.UUID `gorm:"type:uuid;primaryKey" json:"role_id"`
+	Role         Role      `gorm:"foreignKey:RoleID;constraint:OnDelete:CASCADE;" json:"role"`
+	AssignedByID uuid.UUID `gorm:"type:uuid" json:"assigned_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
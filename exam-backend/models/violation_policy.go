@@ -0,0 +1,81 @@
+package models
+
+import "fmt"
+
+// Proctoring events the client can report over /ws/exam.
+const (
+	ViolationTabSwitch          = "tab-switch"
+	ViolationFullscreenExit     = "fullscreen-exit"
+	ViolationCopyPaste          = "copy-paste"
+	ViolationDevtools           = "devtools"
+	ViolationMultipleFaces      = "multiple-faces"
+	ViolationGuardProcessKilled = "guard-process-killed"
+)
+
+// Actions a rule can take once its threshold is reached.
+const (
+	ViolationActionWarn      = "warn"      // send a warning frame on every violation from the threshold on
+	ViolationActionFlag      = "flag"      // mark the attempt for manual review (once)
+	ViolationActionTerminate = "terminate" // end the attempt
+)
+
+var knownViolationEvents = map[string]bool{
+	ViolationTabSwitch:          true,
+	ViolationFullscreenExit:     true,
+	ViolationCopyPaste:          true,
+	ViolationDevtools:           true,
+	ViolationMultipleFaces:      true,
+	ViolationGuardProcessKilled: true,
+}
+
+// IsViolationEvent reports whether the websocket message type is a countable violation.
+func IsViolationEvent(event string) bool {
+	return knownViolationEvents[event]
+}
+
+type ViolationRule struct {
+	Event     string `json:"event"`
+	Threshold int    `json:"threshold"` // number of occurrences that triggers the action
+	Action    string `json:"action"`
+	Message   string `json:"message,omitempty"` // shown to the candidate (warn / terminate)
+}
+
+type ViolationPolicy struct {
+	Rules []ViolationRule `json:"rules"`
+}
+
+// DefaultViolationPolicy reproduces the original hard-coded behaviour:
+// terminate on the 4th tab switch, ignore everything else.
+func DefaultViolationPolicy() ViolationPolicy {
+	return ViolationPolicy{Rules: []ViolationRule{
+		{Event: ViolationTabSwitch, Threshold: 4, Action: ViolationActionTerminate},
+	}}
+}
+
+func (p ViolationPolicy) Validate() error {
+	for i, r := range p.Rules {
+		if !knownViolationEvents[r.Event] {
+			return fmt.Errorf("rule %d: unknown event '%s'", i+1, r.Event)
+		}
+		switch r.Action {
+		case ViolationActionWarn, ViolationActionFlag, ViolationActionTerminate:
+		default:
+			return fmt.Errorf("rule %d: invalid action '%s'. use warn, flag or terminate", i+1, r.Action)
+		}
+		if r.Threshold < 1 {
+			return fmt.Errorf("rule %d: threshold must be at least 1", i+1)
+		}
+	}
+	return nil
+}
+
+// RulesFor returns the rules that apply to one event type.
+func (p ViolationPolicy) RulesFor(event string) []ViolationRule {
+	out := []ViolationRule{}
+	for _, r := range p.Rules {
+		if r.Event == event {
+			out = append(out, r)
+		}
+	}
+	return out
+}
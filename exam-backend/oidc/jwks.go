@@ -0,0 +1,170 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type jwk struct {
+	Kid string
+	Alg string
+	Key interface{} // *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey
+}
+
+type rawJWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func b64int(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func parseJWK(r rawJWK) (interface{}, error) {
+	switch r.Kty {
+	case "RSA":
+		n, err := b64int(r.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64int(r.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch r.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", r.Crv)
+		}
+		x, err := b64int(r.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64int(r.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if r.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", r.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(r.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", r.Kty)
+	}
+}
+
+func (p *Provider) fetchKeys(ctx context.Context, jwksURI string) error {
+	var set struct {
+		Keys []rawJWK `json:"keys"`
+	}
+	if err := p.getJSON(ctx, jwksURI, &set); err != nil {
+		return fmt.Errorf("oidc jwks: %w", err)
+	}
+	keys := map[string]jwk{}
+	for _, r := range set.Keys {
+		if r.Use != "" && r.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(r)
+		if err != nil {
+			continue // skip keys we cannot use rather than failing the whole set
+		}
+		keys[r.Kid] = jwk{Kid: r.Kid, Alg: r.Alg, Key: key}
+	}
+	p.mu.Lock()
+	p.keys, p.keysFetched = keys, time.Now()
+	p.mu.Unlock()
+	return nil
+}
+
+// keyMatchesMethod stops a token from choosing a verification scheme that does
+// not belong to the key (e.g. HS256 keyed with an RSA modulus).
+func keyMatchesMethod(key interface{}, alg string) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		return strings.HasPrefix(alg, "ES")
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	}
+	return false
+}
+
+func (p *Provider) verificationKey(ctx context.Context, t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	lookup := func() (jwk, bool) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if k, ok := p.keys[kid]; ok {
+			return k, true
+		}
+		// providers with a single key may omit kid
+		if kid == "" && len(p.keys) == 1 {
+			for _, k := range p.keys {
+				return k, true
+			}
+		}
+		return jwk{}, false
+	}
+
+	key, ok := lookup()
+	if !ok {
+		p.mu.Lock()
+		stale := time.Since(p.keysFetched) > keysMinRefresh
+		jwksURI := p.meta.JWKSURI
+		p.mu.Unlock()
+		if stale {
+			if err := p.fetchKeys(ctx, jwksURI); err != nil {
+				return nil, err
+			}
+			key, ok = lookup()
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	alg := t.Method.Alg()
+	if (key.Alg != "" && key.Alg != alg) || !keyMatchesMethod(key.Key, alg) {
+		return nil, fmt.Errorf("algorithm %s not allowed for key %q", alg, kid)
+	}
+	return key.Key, nil
+}
@@ -0,0 +1,263 @@
+// Package signing holds the asymmetric keys access tokens are signed with.
+//
+// Keys live as PEM files in JWT_KEYS_DIR (default ./data/jwt-keys); the file name
+// without extension is the key id ("kid"):
+//
+//	<kid>.pem      PKCS#8 private key (RSA ≥ 2048 bits → RS256, Ed25519 → EdDSA)
+//	<kid>.pub.pem  public key only, for a retired key whose tokens may still be live
+//
+// Tokens are signed with JWT_ACTIVE_KID, or with the private key whose kid sorts
+// last, so date-prefixed ids (2026-10-01, 2027-01-01, …) rotate naturally. Every
+// key in the directory verifies tokens. When the directory holds no private key
+// one is generated (JWT_KEY_ALG: EdDSA by default, or RS256).
+//
+// Rotation: add the new <kid>.pem to every instance, restart, then set it active
+// (or let it sort last); once ACCESS_TOKEN_TTL has passed, delete or demote the
+// old key to <kid>.pub.pem.
+package signing
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key is one signing or verification key.
+type Key struct {
+	ID      string
+	Alg     string
+	private crypto.Signer // nil for verification-only keys
+	public  crypto.PublicKey
+}
+
+// KeySet is the active signing key plus every key accepted for verification.
+type KeySet struct {
+	active *Key
+	keys   map[string]*Key
+}
+
+// Current is the process-wide key set; set by Init.
+var Current *KeySet
+
+// Issuer is the "iss" claim of access tokens: JWT_ISSUER, default "exam-backend".
+func Issuer() string {
+	if v := os.Getenv("JWT_ISSUER"); v != "" {
+		return v
+	}
+	return "exam-backend"
+}
+
+// Init loads (or creates) the keys from JWT_KEYS_DIR.
+func Init() error {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		dir = "./data/jwt-keys"
+	}
+	ks, err := LoadDir(dir, os.Getenv("JWT_ACTIVE_KID"))
+	if errors.Is(err, errNoPrivateKey) {
+		kid := time.Now().UTC().Format("2006-01-02T150405")
+		if err := GenerateKey(dir, kid, os.Getenv("JWT_KEY_ALG")); err != nil {
+			return err
+		}
+		log.Printf("🔑 generated JWT signing key %s in %s", kid, dir)
+		ks, err = LoadDir(dir, os.Getenv("JWT_ACTIVE_KID"))
+	}
+	if err != nil {
+		return err
+	}
+	Current = ks
+	log.Printf("🔑 signing access tokens with %s (%s), %d verification key(s)", ks.active.ID, ks.active.Alg, len(ks.keys))
+	return nil
+}
+
+var errNoPrivateKey = errors.New("no private signing key found")
+
+// LoadDir reads every key in dir and picks the signing key.
+func LoadDir(dir, activeKID string) (*KeySet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	ks := &KeySet{keys: map[string]*Key{}}
+	var privateIDs []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".pem") {
+			continue
+		}
+		publicOnly := strings.HasSuffix(name, ".pub.pem")
+		kid := strings.TrimSuffix(strings.TrimSuffix(name, ".pem"), ".pub")
+
+		raw, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		key, err := parseKey(kid, raw, publicOnly)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if existing, dup := ks.keys[kid]; dup && existing.private != nil {
+			continue // keep the private variant when both files exist
+		}
+		ks.keys[kid] = key
+		if key.private != nil {
+			privateIDs = append(privateIDs, kid)
+		}
+	}
+
+	if len(privateIDs) == 0 {
+		return nil, errNoPrivateKey
+	}
+	sort.Strings(privateIDs)
+	if activeKID == "" {
+		activeKID = privateIDs[len(privateIDs)-1]
+	}
+	active, ok := ks.keys[activeKID]
+	if !ok || active.private == nil {
+		return nil, fmt.Errorf("JWT_ACTIVE_KID %q has no private key in %s", activeKID, dir)
+	}
+	ks.active = active
+	return ks, nil
+}
+
+func parseKey(kid string, raw []byte, publicOnly bool) (*Key, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+
+	if publicOnly {
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return newKey(kid, nil, pub)
+	}
+
+	var priv interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	return newKey(kid, signer, signer.Public())
+}
+
+func newKey(kid string, priv crypto.Signer, pub crypto.PublicKey) (*Key, error) {
+	k := &Key{ID: kid, private: priv, public: pub}
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		if p.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		k.Alg = AlgRS256
+	case ed25519.PublicKey:
+		k.Alg = AlgEdDSA
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+	return k, nil
+}
+
+// GenerateKey writes a new private key as dir/<kid>.pem.
+func GenerateKey(dir, kid, alg string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	var priv interface{}
+	switch alg {
+	case "", AlgEdDSA:
+		_, k, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		priv = k
+	case AlgRS256:
+		k, err := rsa.GenerateKey(rand.Reader, 3072)
+		if err != nil {
+			return err
+		}
+		priv = k
+	default:
+		return fmt.Errorf("unsupported JWT_KEY_ALG %q (supported: EdDSA, RS256)", alg)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, kid+".pem")
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+}
+
+func signingMethod(alg string) jwt.SigningMethod {
+	if alg == AlgRS256 {
+		return jwt.SigningMethodRS256
+	}
+	return jwt.SigningMethodEdDSA
+}
+
+// Sign signs claims with the active key and stamps its kid in the header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(signingMethod(ks.active.Alg), claims)
+	token.Header["kid"] = ks.active.ID
+	return token.SignedString(ks.active.private)
+}
+
+// Keyfunc resolves the verification key from the kid header and refuses a token
+// whose alg does not match that key, so neither "none" nor an HMAC token made
+// with a public key gets through.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.Alg {
+		return nil, fmt.Errorf("unexpected signing method %s for key %s", token.Method.Alg(), kid)
+	}
+	return key.public, nil
+}
+
+// Methods lists the algorithms in use, for jwt.WithValidMethods.
+func (ks *KeySet) Methods() []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, k := range ks.keys {
+		if !seen[k.Alg] {
+			seen[k.Alg] = true
+			out = append(out, k.Alg)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
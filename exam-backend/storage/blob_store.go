@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// BlobStore keeps binary objects (webcam snapshots, thumbnails) outside Postgres.
+// Keys are slash-separated paths such as "snapshots/<attempt>/<id>.jpg".
+type BlobStore interface {
+	Put(ctx context.Context, key, contentType string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+var ErrNotFound = errors.New("blob not found")
+
+// Snapshots is the store used for proctoring snapshots; set by InitSnapshotStore.
+var Snapshots BlobStore
+
+// InitSnapshotStore selects the backend from SNAPSHOT_STORE ("local" by default).
+// local: files under SNAPSHOT_DIR (default ./data/snapshots).
+func InitSnapshotStore() error {
+	kind := strings.ToLower(os.Getenv("SNAPSHOT_STORE"))
+	switch kind {
+	case "", "local":
+		dir := os.Getenv("SNAPSHOT_DIR")
+		if dir == "" {
+			dir = "./data/snapshots"
+		}
+		store, err := NewLocalStore(dir)
+		if err != nil {
+			return err
+		}
+		Snapshots = store
+		return nil
+	default:
+		return fmt.Errorf("unsupported SNAPSHOT_STORE %q (supported: local)", kind)
+	}
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+
+	// decoders for image.Decode
+	_ "image/png"
+)
+
+// Largest image Thumbnail will decode. A small compressed upload can declare
+// huge dimensions, so the header is checked before any pixels are allocated.
+const (
+	MaxImageWidth  = 4096
+	MaxImageHeight = 4096
+)
+
+var ErrImageTooLarge = errors.New("image dimensions too large")
+
+// Thumbnail decodes a JPEG/PNG and returns a JPEG no wider than maxWidth
+// (box-filtered, aspect ratio kept) plus the original dimensions.
+func Thumbnail(data []byte, maxWidth int) ([]byte, int, int, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > MaxImageWidth || cfg.Height > MaxImageHeight {
+		return nil, 0, 0, ErrImageTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	tw, th := w, h
+	if w > maxWidth {
+		tw = maxWidth
+		th = h * maxWidth / w
+		if th < 1 {
+			th = 1
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0 := b.Min.Y + y*h/th
+		y1 := b.Min.Y + (y+1)*h/th
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < tw; x++ {
+			x0 := b.Min.X + x*w/tw
+			x1 := b.Min.X + (x+1)*w/tw
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			off := dst.PixOffset(x, y)
+			dst.Pix[off+0] = uint8(r / n >> 8)
+			dst.Pix[off+1] = uint8(g / n >> 8)
+			dst.Pix[off+2] = uint8(bl / n >> 8)
+			dst.Pix[off+3] = uint8(a / n >> 8)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 75}); err != nil {
+		return nil, 0, 0, err
+	}
+	return buf.Bytes(), w, h, nil
+}
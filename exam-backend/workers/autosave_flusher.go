@@ -34,19 +34,25 @@ func flushDirtyAttempts() {
 		answersKey := "attempt:answers:" + attemptID
 		tabsKey := "attempt:tabs:" + attemptID
 
-		var answers map[string]string
-		_ = database.RedisGetJSON(ctx, answersKey, &answers)
-
-		tabsStr, _ := database.RedisGet(ctx, tabsKey)
-		tabs, _ := strconv.Atoi(tabsStr)
+		// answers are stored as JSON text already; map updates bypass GORM's serializer
+		updates := map[string]interface{}{}
+		if answers, err := database.RedisGet(ctx, answersKey); err == nil && answers != "" {
+			updates["answers"] = answers
+		}
+		// only clients on POST /api/progress report tab switches; don't zero the
+		// counter the violation policy maintains when the key was never written
+		if tabsStr, err := database.RedisGet(ctx, tabsKey); err == nil {
+			if tabs, err := strconv.Atoi(tabsStr); err == nil {
+				updates["tab_switches"] = tabs
+			}
+		}
 
 		// Flush to DB
-		database.DB.Model(&models.ExamAttempt{}).
-			Where("id = ? AND submitted_at IS NULL", attemptID).
-			Updates(map[string]interface{}{
-				"answers":      answers,
-				"tab_switches": tabs,
-			})
+		if len(updates) > 0 {
+			database.DB.Model(&models.ExamAttempt{}).
+				Where("id = ? AND submitted_at IS NULL", attemptID).
+				Updates(updates)
+		}
 
 		// Clear dirty flag
 		_ = database.RedisDel(ctx, dirtyKey)
@@ -0,0 +1,71 @@
+package workers
+
+import (
+	"log"
+	"time"
+
+	"exam-backend/database"
+	"exam-backend/models"
+)
+
+// Proctoring events arrive from every open /ws/exam socket. They are buffered
+// and written in batches so 2000 candidates don't mean 2000 single-row INSERTs.
+
+const (
+	eventBufferSize    = 10000
+	eventBatchSize     = 500
+	eventFlushInterval = time.Second
+)
+
+var eventQueue = make(chan models.AttemptEvent, eventBufferSize)
+
+// EnqueueAttemptEvent never blocks the websocket; events are dropped if the writer falls behind.
+func EnqueueAttemptEvent(ev models.AttemptEvent) {
+	if ev.ServerTime.IsZero() {
+		ev.ServerTime = time.Now().UTC()
+	}
+	select {
+	case eventQueue <- ev:
+	default:
+		log.Printf("event writer: queue full, dropping %s event for attempt %s", ev.Type, ev.AttemptID)
+	}
+}
+
+func StartEventWriter() {
+	ticker := time.NewTicker(eventFlushInterval)
+
+	go func() {
+		batch := make([]models.AttemptEvent, 0, eventBatchSize)
+		for {
+			select {
+			case ev := <-eventQueue:
+				batch = append(batch, ev)
+				if len(batch) >= eventBatchSize {
+					batch = writeEvents(batch)
+				}
+			case <-ticker.C:
+				if len(batch) > 0 {
+					batch = writeEvents(batch)
+				}
+			}
+		}
+	}()
+}
+
+func writeEvents(batch []models.AttemptEvent) []models.AttemptEvent {
+	if err := database.DB.CreateInBatches(batch, eventBatchSize).Error; err != nil {
+		// one bad row fails the whole INSERT; retry one by one so only that row is lost
+		log.Printf("event writer: batch of %d events failed, retrying row by row: %v", len(batch), err)
+		dropped := 0
+		for i := range batch {
+			if err := database.DB.Create(&batch[i]).Error; err != nil {
+				dropped++
+				log.Printf("event writer: dropping %s event for attempt %s: %v", batch[i].Type, batch[i].AttemptID, err)
+			}
+		}
+		if dropped > 0 {
+			log.Printf("event writer: stored %d of %d events", len(batch)-dropped, len(batch))
+		}
+	}
+	return batch[:0]
+}
@@ -9,7 +9,6 @@ export function Auth() {
   const [email, setEmail] = React.useState('');
   const [password, setPassword] = React.useState('');
   const [fullName, setFullName] = React.useState('');
-  const [role, setRole] = React.useState<'admin' | 'student' | 'teacher'>('student');
   const [error, setError] = React.useState('');
   const [loading, setLoading] = React.useState(false);
 
@@ -23,7 +22,7 @@ export function Auth() {
 
     try {
       if (isSignUp) {
-        await signUp(email, password, fullName, role);
+        await signUp(email, password, fullName);
         await signIn(email, password);
       } else {
         await signIn(email, password);
@@ -131,28 +130,6 @@ export function Auth() {
             />
           </div>
 
-          {isSignUp && (
-            <div>
-              <label htmlFor="role" className="block text-sm font-medium text-slate-700 dark:text-slate-300 mb-2">
-                Role
-              </label>
-              <select
-                id="role"
-                value={role}
-                onChange={(e) => setRole(e.target.value as 'admin' | 'student' | 'teacher')}
-                className="
-                  w-full px-4 py-3 rounded-lg border outline-none transition appearance-none
-                  bg-white border-slate-300 text-slate-900 focus:ring-2 focus:ring-sky-500 focus:border-transparent
-                  dark:bg-slate-950 dark:border-slate-700 dark:text-white dark:focus:ring-sky-600
-                "
-              >
-                <option value="student">Student</option>
-                <option value="teacher">Teacher</option>
-                <option value="admin">Admin</option>
-              </select>
-            </div>
-          )}
-
           {error && (
             <div className="
               p-3 rounded-lg text-sm border
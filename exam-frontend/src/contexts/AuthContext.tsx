@@ -14,7 +14,7 @@ type AuthContextType = {
   user: User;
   loading: boolean;
   signIn: (email: string, password: string) => Promise<void>;
-  signUp: (email: string, password: string, fullName: string, role: 'admin' | 'student' | 'teacher') => Promise<void>;
+  signUp: (email: string, password: string, fullName: string) => Promise<void>;
   signOut: () => void;
 };
 
@@ -49,9 +49,10 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
         fingerprint: visitorId // <--- Backend expects this now
       });
 
-      const { token, user } = response.data;
+      const { token, refresh_token, user } = response.data;
 
       localStorage.setItem('token', token);
+      localStorage.setItem('refresh_token', refresh_token);
       localStorage.setItem('user', JSON.stringify(user));
       setUser(user);
     } catch (error: any) {
@@ -69,17 +70,23 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
   }
   // ---------------------------------------------------------------
 
-  async function signUp(email: string, password: string, fullName: string, role: 'admin' | 'student' | 'teacher') {
+  // self-registration creates students only; staff accounts come from admins / invites
+  async function signUp(email: string, password: string, fullName: string) {
     await api.post('/auth/register', {
       email,
       password,
-      full_name: fullName,
-      role
+      full_name: fullName
     });
   }
 
   function signOut() {
+    // end the server session too; local state is cleared regardless
+    const token = localStorage.getItem('token');
+    if (token) {
+      api.post('/auth/logout', {}, { headers: { Authorization: `Bearer ${token}` } }).catch(() => {});
+    }
     localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
     localStorage.removeItem('user');
     setUser({} as User);
   }
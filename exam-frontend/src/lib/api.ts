@@ -18,4 +18,44 @@ api.interceptors.request.use(
     (error) => Promise.reject(error)
 );
 
+// Access tokens are short-lived: on 401, trade the refresh token for a new pair
+// once (shared by concurrent requests) and replay the request.
+let refreshing: Promise<string | null> | null = null;
+
+function refreshAccessToken(): Promise<string | null> {
+    const refreshToken = localStorage.getItem("refresh_token");
+    if (!refreshToken) return Promise.resolve(null);
+
+    if (!refreshing) {
+        refreshing = axios
+            .post(`${API_BASE_URL}/auth/refresh`, { refresh_token: refreshToken })
+            .then((res) => {
+                localStorage.setItem("token", res.data.token);
+                localStorage.setItem("refresh_token", res.data.refresh_token);
+                return res.data.token as string;
+            })
+            .catch(() => null)
+            .finally(() => {
+                refreshing = null;
+            });
+    }
+    return refreshing;
+}
+
+api.interceptors.response.use(
+    (response) => response,
+    async (error) => {
+        const original = error.config;
+        if (error.response?.status === 401 && original && !original._retried) {
+            original._retried = true;
+            const token = await refreshAccessToken();
+            if (token) {
+                original.headers.Authorization = `Bearer ${token}`;
+                return api(original);
+            }
+        }
+        return Promise.reject(error);
+    }
+);
+
 export default api;
\ No newline at end of file